@@ -0,0 +1,49 @@
+package qcache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+const benchKeys = 1 << 14
+
+func benchCaches(b *testing.B, c *Caches[int], writeEvery int) {
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+		c.Set(keys[i], i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i&(benchKeys-1)]
+			if writeEvery > 0 && i%writeEvery == 0 {
+				c.Set(key, i)
+			} else {
+				c.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+	b.Run("go-cache", func(b *testing.B) {
+		benchCaches(b, NewCaches[int](time.Hour, 0, nil), 0)
+	})
+	b.Run("sharded", func(b *testing.B) {
+		benchCaches(b, NewShardedCaches[int](0, time.Hour, 0, nil), 0)
+	})
+}
+
+func BenchmarkMixedParallel(b *testing.B) {
+	b.Run("go-cache", func(b *testing.B) {
+		benchCaches(b, NewCaches[int](time.Hour, 0, nil), 10)
+	})
+	b.Run("sharded", func(b *testing.B) {
+		benchCaches(b, NewShardedCaches[int](0, time.Hour, 0, nil), 10)
+	})
+}
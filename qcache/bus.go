@@ -0,0 +1,131 @@
+package qcache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Transport 失效通知的传输层，需保证Publish的消息能送达同一主机上的其他订阅者
+type Transport interface {
+	// Publish 广播一条消息
+	Publish(msg []byte) error
+	// Subscribe 注册消息处理方法，传输层在后台goroutine中回调
+	Subscribe(handler func(msg []byte)) error
+	// Close 关闭传输层
+	Close() error
+}
+
+const (
+	opInvalidateKey = "del"
+	opInvalidateAll = "flush"
+)
+
+// invalidation 在进程间传递的失效消息
+type invalidation struct {
+	Node  string `json:"n"` // 发送方节点Id，用于忽略自己发出的消息
+	Cache string `json:"c"` // 缓存名称
+	Op    string `json:"o"`
+	Key   string `json:"k,omitempty"`
+}
+
+// Bus 缓存失效总线，同一总线上的多个缓存按名称区分
+type Bus struct {
+	transport Transport
+	node      string
+	mu        sync.RWMutex
+	handlers  map[string][]func(msg invalidation)
+}
+
+// NewBus 创建缓存失效总线
+//
+//	@param transport 传输层，如 NewUnixTransport、NewMulticastTransport
+//	@return *Bus
+//	@return error
+func NewBus(transport Transport) (*Bus, error) {
+	if transport == nil {
+		return nil, errors.New("bus transport is nil")
+	}
+	b := &Bus{
+		transport: transport,
+		node:      newNodeId(),
+		handlers:  make(map[string][]func(msg invalidation)),
+	}
+	if err := transport.Subscribe(b.receive); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Close 关闭总线和传输层
+//
+//	@return error
+func (b *Bus) Close() error {
+	return b.transport.Close()
+}
+
+// publish 广播失效消息
+func (b *Bus) publish(cacheName string, op string, key string) error {
+	msg, err := json.Marshal(invalidation{Node: b.node, Cache: cacheName, Op: op, Key: key})
+	if err != nil {
+		return err
+	}
+	return b.transport.Publish(msg)
+}
+
+// register 注册指定缓存名称的消息处理方法
+func (b *Bus) register(cacheName string, handler func(msg invalidation)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[cacheName] = append(b.handlers[cacheName], handler)
+}
+
+// receive 处理传输层收到的消息
+func (b *Bus) receive(data []byte) {
+	var msg invalidation
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	if msg.Node == b.node {
+		return // 忽略自己发出的消息
+	}
+	b.mu.RLock()
+	handlers := b.handlers[msg.Cache]
+	b.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(msg)
+	}
+}
+
+// Attach 将缓存接入失效总线，此后Delete、Flush会通知其他进程中同名的缓存一并执行
+//
+//	@param bus 失效总线
+//	@param name 缓存名称，不同进程中需一致
+func (c *Caches[T]) Attach(bus *Bus, name string) {
+	c.bus = bus
+	c.busName = name
+	bus.register(name, c.onInvalidation)
+}
+
+// onInvalidation 处理其他进程发来的失效消息，只在本地执行，不再广播
+func (c *Caches[T]) onInvalidation(msg invalidation) {
+	switch msg.Op {
+	case opInvalidateKey:
+		c.deleteLocal(msg.Key)
+	case opInvalidateAll:
+		c.flushLocal()
+	}
+}
+
+// newNodeId 生成当前总线实例的唯一标识
+func newNodeId() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", os.Getpid())
+	}
+	return fmt.Sprintf("%d-%s", os.Getpid(), hex.EncodeToString(buf))
+}
@@ -0,0 +1,86 @@
+package qcache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBusInvalidatesPeers(t *testing.T) {
+	dir := t.TempDir()
+	var caches []*Caches[string]
+	for i := 0; i < 3; i++ {
+		transport, err := NewUnixTransport(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bus, err := NewBus(transport)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer bus.Close()
+		c := NewCaches[string](time.Minute, 0, nil)
+		c.Attach(bus, "users")
+		c.Set("u1", "alice")
+		c.Set("u2", "bob")
+		caches = append(caches, c)
+	}
+
+	// 同一总线上名称不同的缓存不受影响
+	other := NewCaches[string](time.Minute, 0, nil)
+	otherTransport, err := NewUnixTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherBus, err := NewBus(otherTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherBus.Close()
+	other.Attach(otherBus, "orders")
+	other.Set("u1", "order")
+
+	caches[0].Delete("u1")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for _, c := range caches {
+		for {
+			if _, ok := c.Get("u1"); !ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("peer cache was not invalidated")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if _, ok := c.Get("u2"); !ok {
+			t.Fatal("unrelated key must be kept")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := other.Get("u1"); !ok {
+		t.Fatal("cache with another name must be kept")
+	}
+}
+
+func TestUnixTransportRemovesStalePeers(t *testing.T) {
+	dir := t.TempDir()
+	t1, err := NewUnixTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer t1.Close()
+	t2, err := NewUnixTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 模拟对方进程崩溃，套接字文件残留
+	_ = t2.(*unixTransport).conn.Close()
+
+	if err = t1.Publish([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(t2.(*unixTransport).path); !os.IsNotExist(err) {
+		t.Fatal("stale socket should be removed")
+	}
+}
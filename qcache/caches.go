@@ -1,16 +1,24 @@
 package qcache
 
 import (
-	"github.com/patrickmn/go-cache"
+	"context"
+	"os"
+	"runtime"
 	"sync"
 	"time"
 )
 
 type Caches[T any] struct {
-	caches          *cache.Cache
+	caches          store[T]
 	findingCallback func(key string) (T, bool)
 	mu              sync.RWMutex        // 用于保护findingCallback的并发执行
 	callbackKeys    map[string]struct{} // 记录正在执行callback的key，防止重复执行
+	tier            *memTier            // 内存层的LRU记录，未启用二级缓存时为nil
+	disk            *diskStore          // 磁盘二级缓存，未启用时为nil
+	bus             *Bus                // 失效总线，未接入时为nil
+	busName         string              // 在失效总线上的缓存名称
+	loader          *loader[T]          // 支持上下文的加载器，未设置时为nil
+	stats           cacheStats          // 命中与加载统计
 }
 
 // NewCaches 创建缓存
@@ -21,13 +29,38 @@ type Caches[T any] struct {
 //	@return *Caches[T]
 func NewCaches[T any](defaultExpiration, cleanupInterval time.Duration, findingCallback func(key string) (T, bool)) *Caches[T] {
 	c := &Caches[T]{
-		caches:          cache.New(defaultExpiration, cleanupInterval),
+		caches:          newGoStore[T](defaultExpiration, cleanupInterval),
 		findingCallback: findingCallback,
 		callbackKeys:    make(map[string]struct{}),
 	}
 	return c
 }
 
+// NewShardedCaches 创建分片缓存，按key哈希分散到多个独立加锁的分片，值按类型保存不做装箱，适合高并发读
+//
+//	除SaveToFile保存的文件格式与NewCaches不通用外，其余行为与NewCaches一致
+//	@param shards 分片数，向上取2的幂，0使用 GOMAXPROCS*4
+//	@param defaultExpiration 缓存项的默认过期时间
+//	@param cleanupInterval 清理过期缓存项的时间间隔 0不清理 非0间隔清理
+//	@param findingCallback Get缓存不存在时，主动查找回调方法
+//	@return *Caches[T]
+func NewShardedCaches[T any](shards int, defaultExpiration, cleanupInterval time.Duration, findingCallback func(key string) (T, bool)) *Caches[T] {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0) * 4
+	}
+	s := newShardedStore[T](shards, defaultExpiration, cleanupInterval)
+	c := &Caches[T]{
+		caches:          s,
+		findingCallback: findingCallback,
+		callbackKeys:    make(map[string]struct{}),
+	}
+	// 缓存被回收时停止清理goroutine
+	runtime.SetFinalizer(c, func(*Caches[T]) {
+		s.stopJanitor()
+	})
+	return c
+}
+
 // Set 写入缓存，使用默认的缓存有效期
 //
 //	@param key
@@ -37,7 +70,11 @@ func (c *Caches[T]) Set(key string, value T) {
 	if key == "" {
 		return // 忽略空的key
 	}
-	c.caches.Set(key, value, cache.DefaultExpiration)
+	if c.disk != nil {
+		c.setTiered(key, value, DefaultExpiration)
+		return
+	}
+	c.caches.Set(key, value, DefaultExpiration)
 }
 
 // SetWithNewExpiration 写入缓存, 使用新的缓存有效期
@@ -49,6 +86,10 @@ func (c *Caches[T]) SetWithNewExpiration(key string, value T, newExpiration time
 	if key == "" {
 		return // 忽略空的key
 	}
+	if c.disk != nil {
+		c.setTiered(key, value, newExpiration)
+		return
+	}
 	c.caches.Set(key, value, newExpiration)
 }
 
@@ -63,8 +104,17 @@ func (c *Caches[T]) Get(key string) (T, bool) {
 		return zero, false
 	}
 
-	value, exist := c.caches.Get(key)
-	if exist == false && c.findingCallback != nil {
+	if cached, ok := c.lookup(key); ok {
+		return cached, true
+	}
+	if c.loader != nil {
+		loaded, ok, _ := c.load(context.Background(), key)
+		return loaded, ok
+	}
+
+	var value T
+	exist := false
+	if c.findingCallback != nil {
 		// 检查是否有其他goroutine正在为这个key执行callback
 		c.mu.Lock()
 		if _, inProgress := c.callbackKeys[key]; inProgress {
@@ -92,7 +142,7 @@ func (c *Caches[T]) Get(key string) (T, bool) {
 			c.mu.Unlock()
 
 			if ok == true {
-				c.caches.Set(key, newValue, cache.DefaultExpiration)
+				c.Set(key, newValue)
 				value = newValue
 				exist = true
 			}
@@ -103,24 +153,51 @@ func (c *Caches[T]) Get(key string) (T, bool) {
 		var zero T
 		return zero, false
 	}
+	return value, true
+}
 
-	// 安全的类型断言
-	if typedValue, ok := value.(T); ok {
-		return typedValue, exist
+// lookup 从内存层和磁盘层查找缓存并记录命中统计，不触发查找回调
+func (c *Caches[T]) lookup(key string) (T, bool) {
+	if c.disk == nil {
+		return c.caches.lookup(key)
 	}
-
-	// 类型断言失败，返回零值
-	var zero T
-	return zero, false
+	value, exist := c.caches.Get(key)
+	if exist {
+		c.touchTiered(key)
+	} else {
+		// 磁盘层命中时提升回内存
+		value, exist = c.promote(key)
+	}
+	if exist {
+		c.stats.hits.Add(1)
+	} else {
+		c.stats.misses.Add(1)
+	}
+	return value, exist
 }
 
-// Delete 删除缓存
+// Delete 删除缓存，接入失效总线时同时通知其他进程删除
 //
 //	@param key
 func (c *Caches[T]) Delete(key string) {
 	if key == "" {
 		return // 忽略空的key
 	}
+	c.deleteLocal(key)
+	if c.bus != nil {
+		_ = c.bus.publish(c.busName, opInvalidateKey, key)
+	}
+}
+
+// deleteLocal 只删除本进程中的缓存
+func (c *Caches[T]) deleteLocal(key string) {
+	if key == "" {
+		return
+	}
+	if c.disk != nil {
+		c.deleteTiered(key)
+		return
+	}
 	c.caches.Delete(key)
 }
 
@@ -129,7 +206,15 @@ func (c *Caches[T]) Delete(key string) {
 //	@param filePath 文件路径
 //	@return error
 func (c *Caches[T]) SaveToFile(filePath string) error {
-	return c.caches.SaveFile(filePath)
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err = c.caches.Save(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
 }
 
 // LoadFromFile 从文件加载缓存
@@ -137,5 +222,10 @@ func (c *Caches[T]) SaveToFile(filePath string) error {
 //	@param filePath 文件路径
 //	@return error
 func (c *Caches[T]) LoadFromFile(filePath string) error {
-	return c.caches.LoadFile(filePath)
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.caches.Load(f)
 }
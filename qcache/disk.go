@@ -0,0 +1,430 @@
+package qcache
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	diskFileName   = "cache.log" // 磁盘缓存日志文件名
+	diskHeaderSize = 8           // 记录头：crc32(4) + 负载长度(4)
+	diskMinCompact = 1 << 20     // 日志文件小于该值时不做压缩
+	diskLowWater   = 90          // 超出预算时丢弃到预算的该百分比，避免之后每次写入都要丢弃
+
+	opPut    byte = 1
+	opDelete byte = 2
+)
+
+var errBadRecord = errors.New("bad cache record")
+
+// diskEntry 磁盘中一条有效记录的索引
+type diskEntry struct {
+	key       string
+	offset    int64 // 记录在文件中的起始位置
+	length    int64 // 记录总长度（含记录头）
+	valueOff  int64 // 值在文件中的起始位置
+	valueLen  int64
+	expiresAt int64 // 过期时间 UnixNano，0表示永不过期
+}
+
+// diskStore 以追加日志形式保存的磁盘缓存
+type diskStore struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	size   int64 // 日志文件当前大小
+	live   int64 // 有效记录占用的字节数
+	budget int64 // 磁盘字节预算，0表示不限制
+	index  map[string]*list.Element
+	order  *list.List // 按写入顺序排列，最旧的在前
+}
+
+// openDiskStore 打开磁盘缓存，并从日志中恢复索引
+//
+//	@param dir 缓存目录
+//	@param budget 磁盘字节预算
+//	@return *diskStore
+//	@return error
+func openDiskStore(dir string, budget int64) (*diskStore, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	d := &diskStore{
+		path:   filepath.Join(dir, diskFileName),
+		budget: budget,
+		index:  make(map[string]*list.Element),
+		order:  list.New(),
+	}
+	// 上次异常退出时可能残留压缩用的临时文件
+	_ = os.Remove(d.path + ".tmp")
+
+	file, err := os.OpenFile(d.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	d.file = file
+	if err = d.recover(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// recover 顺序扫描日志重建索引，遇到损坏或不完整的记录时截断文件
+func (d *diskStore) recover() error {
+	if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	info, err := d.file.Stat()
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(d.file)
+	now := time.Now().UnixNano()
+	var offset int64
+	for {
+		op, key, value, expiresAt, n, err := readRecord(reader, info.Size()-offset)
+		if err != nil {
+			// 末尾的残缺记录来自异常退出，直接丢弃
+			break
+		}
+		switch op {
+		case opPut:
+			d.remove(key)
+			if expiresAt == 0 || expiresAt > now {
+				d.add(&diskEntry{
+					key:       key,
+					offset:    offset,
+					length:    n,
+					valueOff:  offset + n - int64(len(value)),
+					valueLen:  int64(len(value)),
+					expiresAt: expiresAt,
+				})
+			}
+		case opDelete:
+			d.remove(key)
+		}
+		offset += n
+	}
+	if err := d.file.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := d.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	d.size = offset
+	return nil
+}
+
+// put 写入一条记录
+//
+//	@param key
+//	@param value 已编码的值
+//	@param expiresAt 过期时间 UnixNano，0表示永不过期
+//	@return error
+func (d *diskStore) put(key string, value []byte, expiresAt int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rec := encodeRecord(opPut, key, value, expiresAt)
+	if _, err := d.file.Write(rec); err != nil {
+		return err
+	}
+	d.remove(key)
+	d.add(&diskEntry{
+		key:       key,
+		offset:    d.size,
+		length:    int64(len(rec)),
+		valueOff:  d.size + int64(len(rec)-len(value)),
+		valueLen:  int64(len(value)),
+		expiresAt: expiresAt,
+	})
+	d.size += int64(len(rec))
+
+	// 超出预算时丢弃最旧的条目直到低水位，写入删除标记保证重启后不会恢复，失效的记录由maybeCompact批量回收
+	if d.budget > 0 && d.live > d.budget {
+		low := d.budget * diskLowWater / 100
+		var tombstones []byte
+		for d.live > low && d.order.Len() > 0 {
+			evicted := d.order.Front().Value.(*diskEntry).key
+			tombstones = append(tombstones, encodeRecord(opDelete, evicted, nil, 0)...)
+			d.remove(evicted)
+		}
+		if _, err := d.file.Write(tombstones); err != nil {
+			return err
+		}
+		d.size += int64(len(tombstones))
+	}
+	return d.maybeCompact()
+}
+
+// get 读取记录，已过期的记录视为不存在
+//
+//	@param key
+//	@return []byte 已编码的值
+//	@return int64 过期时间
+//	@return bool 是否存在
+func (d *diskStore) get(key string) ([]byte, int64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	elem, ok := d.index[key]
+	if !ok {
+		return nil, 0, false
+	}
+	entry := elem.Value.(*diskEntry)
+	if entry.expiresAt > 0 && entry.expiresAt <= time.Now().UnixNano() {
+		d.remove(key)
+		return nil, 0, false
+	}
+	value := make([]byte, entry.valueLen)
+	if _, err := d.file.ReadAt(value, entry.valueOff); err != nil {
+		return nil, 0, false
+	}
+	return value, entry.expiresAt, true
+}
+
+// delete 删除记录，写入删除标记保证重启后不会恢复
+//
+//	不在索引中的key同样写入删除标记，覆盖日志中可能残留的旧记录
+//	@param key
+func (d *diskStore) delete(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rec := encodeRecord(opDelete, key, nil, 0)
+	if _, err := d.file.Write(rec); err != nil {
+		return
+	}
+	d.size += int64(len(rec))
+	d.remove(key)
+	_ = d.maybeCompact()
+}
+
+// keys 返回磁盘中所有未过期的key
+func (d *diskStore) keys() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	keys := make([]string, 0, len(d.index))
+	for e := d.order.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*diskEntry)
+		if entry.expiresAt == 0 || entry.expiresAt > now {
+			keys = append(keys, entry.key)
+		}
+	}
+	return keys
+}
+
+// diskItem 磁盘中一条记录的快照
+type diskItem struct {
+	key       string
+	value     []byte
+	expiresAt int64
+}
+
+// snapshot 读取磁盘中所有未过期的记录
+func (d *diskStore) snapshot() []diskItem {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	items := make([]diskItem, 0, len(d.index))
+	for e := d.order.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*diskEntry)
+		if entry.expiresAt > 0 && entry.expiresAt <= now {
+			continue
+		}
+		value := make([]byte, entry.valueLen)
+		if _, err := d.file.ReadAt(value, entry.valueOff); err != nil {
+			continue
+		}
+		items = append(items, diskItem{key: entry.key, value: value, expiresAt: entry.expiresAt})
+	}
+	return items
+}
+
+// expiration 返回记录的过期时间
+//
+//	@param key
+//	@return int64 过期时间 UnixNano，0表示永不过期
+//	@return bool 是否存在
+func (d *diskStore) expiration(key string) (int64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	elem, ok := d.index[key]
+	if !ok {
+		return 0, false
+	}
+	entry := elem.Value.(*diskEntry)
+	if entry.expiresAt > 0 && entry.expiresAt <= time.Now().UnixNano() {
+		return 0, false
+	}
+	return entry.expiresAt, true
+}
+
+// clear 清空磁盘缓存
+func (d *diskStore) clear() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	d.size = 0
+	d.live = 0
+	d.index = make(map[string]*list.Element)
+	d.order.Init()
+	return nil
+}
+
+// bytes 返回有效记录占用的字节数
+func (d *diskStore) bytes() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.live
+}
+
+// close 关闭磁盘缓存
+func (d *diskStore) close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.file.Close()
+}
+
+func (d *diskStore) add(entry *diskEntry) {
+	d.index[entry.key] = d.order.PushBack(entry)
+	d.live += entry.length
+}
+
+func (d *diskStore) remove(key string) {
+	if elem, ok := d.index[key]; ok {
+		d.live -= elem.Value.(*diskEntry).length
+		d.order.Remove(elem)
+		delete(d.index, key)
+	}
+}
+
+// maybeCompact 无效记录过多时压缩日志
+func (d *diskStore) maybeCompact() error {
+	minSize := int64(diskMinCompact)
+	if d.budget > 0 && d.budget < minSize {
+		// 设置了较小的预算时，日志文件约不超过预算的2倍
+		minSize = d.budget
+	}
+	if d.size < minSize || d.size < d.live*2 {
+		return nil
+	}
+	return d.compact()
+}
+
+// compact 将有效记录重写到新文件，再替换原日志
+func (d *diskStore) compact() error {
+	tmpPath := d.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	var offset int64
+	for e := d.order.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*diskEntry)
+		rec := make([]byte, entry.length)
+		if _, err = d.file.ReadAt(rec, entry.offset); err != nil {
+			break
+		}
+		if _, err = writer.Write(rec); err != nil {
+			break
+		}
+		entry.valueOff = offset + (entry.valueOff - entry.offset)
+		entry.offset = offset
+		offset += entry.length
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("compact cache log: %v", err)
+	}
+	if err = os.Rename(tmpPath, d.path); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	_ = d.file.Close()
+	d.file = tmp
+	d.size = offset
+	_, err = d.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// encodeRecord 编码一条日志记录
+//
+//	记录格式：crc32(4) | 负载长度(4) | op(1) | 过期时间(8) | key长度(4) | key | value
+func encodeRecord(op byte, key string, value []byte, expiresAt int64) []byte {
+	payloadLen := 1 + 8 + 4 + len(key) + len(value)
+	rec := make([]byte, diskHeaderSize+payloadLen)
+	payload := rec[diskHeaderSize:]
+	payload[0] = op
+	binary.LittleEndian.PutUint64(payload[1:9], uint64(expiresAt))
+	binary.LittleEndian.PutUint32(payload[9:13], uint32(len(key)))
+	copy(payload[13:], key)
+	copy(payload[13+len(key):], value)
+	binary.LittleEndian.PutUint32(rec[0:4], crc32.ChecksumIEEE(payload))
+	binary.LittleEndian.PutUint32(rec[4:8], uint32(payloadLen))
+	return rec
+}
+
+// readRecord 读取并校验一条日志记录
+//
+//	@param r
+//	@param remaining 文件剩余字节数，用于识别被截断的记录
+func readRecord(r io.Reader, remaining int64) (op byte, key string, value []byte, expiresAt int64, n int64, err error) {
+	header := make([]byte, diskHeaderSize)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	sum := binary.LittleEndian.Uint32(header[0:4])
+	payloadLen := binary.LittleEndian.Uint32(header[4:8])
+	if payloadLen < 13 || int64(diskHeaderSize+payloadLen) > remaining {
+		err = errBadRecord
+		return
+	}
+	payload := make([]byte, payloadLen)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if crc32.ChecksumIEEE(payload) != sum {
+		err = errBadRecord
+		return
+	}
+	keyLen := binary.LittleEndian.Uint32(payload[9:13])
+	if uint32(len(payload)-13) < keyLen {
+		err = errBadRecord
+		return
+	}
+	op = payload[0]
+	expiresAt = int64(binary.LittleEndian.Uint64(payload[1:9]))
+	key = string(payload[13 : 13+keyLen])
+	value = payload[13+keyLen:]
+	n = int64(diskHeaderSize + payloadLen)
+	return
+}
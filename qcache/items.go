@@ -0,0 +1,170 @@
+package qcache
+
+import (
+	"encoding/json"
+	"github.com/patrickmn/go-cache"
+	"sort"
+	"time"
+)
+
+const (
+	// NoExpiration 永不过期
+	NoExpiration = cache.NoExpiration
+	// DefaultExpiration 使用创建缓存时指定的默认有效期
+	DefaultExpiration = cache.DefaultExpiration
+)
+
+// Item 缓存项快照
+type Item[T any] struct {
+	Value     T
+	ExpiresAt time.Time // 过期时间，零值表示永不过期
+}
+
+// Len 获取未过期的缓存项数量，包含磁盘二级缓存中的条目
+//
+//	@return int
+func (c *Caches[T]) Len() int {
+	return len(c.keySet())
+}
+
+// Keys 获取所有未过期的key，按字典序排列
+//
+//	@return []string
+func (c *Caches[T]) Keys() []string {
+	set := c.keySet()
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// keySet 获取所有未过期的key，内存层与磁盘层在同一时刻取快照并去重，正在溢出或提升的key只出现一次
+func (c *Caches[T]) keySet() map[string]struct{} {
+	if c.disk != nil {
+		c.tier.mu.Lock()
+		defer c.tier.mu.Unlock()
+	}
+	items := c.caches.Items()
+	set := make(map[string]struct{}, len(items))
+	for k := range items {
+		set[k] = struct{}{}
+	}
+	if c.disk != nil {
+		for _, k := range c.disk.keys() {
+			set[k] = struct{}{}
+		}
+	}
+	return set
+}
+
+// Items 获取所有未过期缓存项的快照，快照与缓存互不影响
+//
+//	@return map[string]Item[T]
+func (c *Caches[T]) Items() map[string]Item[T] {
+	if c.disk != nil {
+		c.tier.mu.Lock()
+		defer c.tier.mu.Unlock()
+	}
+	result := c.caches.Items()
+	if c.disk != nil {
+		for _, item := range c.disk.snapshot() {
+			if _, exist := result[item.key]; exist {
+				continue
+			}
+			var value T
+			if err := json.Unmarshal(item.value, &value); err != nil {
+				continue
+			}
+			result[item.key] = Item[T]{Value: value, ExpiresAt: expiresAt(item.expiresAt)}
+		}
+	}
+	return result
+}
+
+// Range 按key的字典序遍历缓存项，回调返回false时停止遍历
+//
+//	遍历的是调用时刻的快照，回调中可以安全地读写缓存，但修改不会反映到本次遍历中
+//	@param fn 回调方法，expiresAt为零值表示永不过期
+func (c *Caches[T]) Range(fn func(key string, v T, expiresAt time.Time) bool) {
+	items := c.Items()
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !fn(k, items[k].Value, items[k].ExpiresAt) {
+			return
+		}
+	}
+}
+
+// Flush 清空缓存，接入失效总线时同时通知其他进程清空
+func (c *Caches[T]) Flush() {
+	c.flushLocal()
+	if c.bus != nil {
+		_ = c.bus.publish(c.busName, opInvalidateAll, "")
+	}
+}
+
+// flushLocal 只清空本进程中的缓存
+func (c *Caches[T]) flushLocal() {
+	if c.disk != nil {
+		c.tier.mu.Lock()
+		defer c.tier.mu.Unlock()
+		c.caches.Flush()
+		c.tier.reset()
+		_ = c.disk.clear()
+		return
+	}
+	c.caches.Flush()
+}
+
+// TTL 获取缓存项的剩余有效期
+//
+//	@param key
+//	@return time.Duration 剩余有效期，永不过期时返回 NoExpiration
+//	@return bool 缓存项是否存在
+func (c *Caches[T]) TTL(key string) (time.Duration, bool) {
+	if key == "" {
+		return 0, false
+	}
+	if _, expiration, ok := c.caches.GetWithExpiration(key); ok {
+		if expiration.IsZero() {
+			return NoExpiration, true
+		}
+		return time.Until(expiration), true
+	}
+	if c.disk != nil {
+		if expiresAt, ok := c.disk.expiration(key); ok {
+			if expiresAt == 0 {
+				return NoExpiration, true
+			}
+			return time.Until(time.Unix(0, expiresAt)), true
+		}
+	}
+	return 0, false
+}
+
+// Touch 重新设置已存在缓存项的有效期，值保持不变
+//
+//	不会用旧值覆盖并发写入的新值，也不会恢复并发删除的缓存项
+//	@param key
+//	@param newExpiration 新的有效期，DefaultExpiration使用默认有效期，NoExpiration永不过期
+//	@return bool 缓存项是否存在
+func (c *Caches[T]) Touch(key string, newExpiration time.Duration) bool {
+	if key == "" {
+		return false
+	}
+	if c.disk != nil {
+		c.tier.mu.Lock()
+		defer c.tier.mu.Unlock()
+		// 磁盘中的条目先提升回内存
+		if _, ok := c.promoteLocked(key); !ok {
+			return false
+		}
+	}
+	return c.caches.Touch(key, newExpiration)
+}
@@ -0,0 +1,120 @@
+package qcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestItemsAndRange(t *testing.T) {
+	c := NewCaches[int](time.Minute, 0, nil)
+	c.Set("b", 2)
+	c.Set("a", 1)
+	c.SetWithNewExpiration("c", 3, NoExpiration)
+	c.SetWithNewExpiration("expired", 4, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if n := c.Len(); n != 3 {
+		t.Fatalf("Len = %d", n)
+	}
+	keys := c.Keys()
+	if fmt.Sprint(keys) != "[a b c]" {
+		t.Fatalf("Keys = %v", keys)
+	}
+	items := c.Items()
+	if !items["c"].ExpiresAt.IsZero() || items["a"].ExpiresAt.IsZero() {
+		t.Fatalf("unexpected expiration in snapshot: %+v", items)
+	}
+
+	// 遍历过程中修改缓存不影响本次遍历
+	var visited []string
+	c.Range(func(key string, v int, expiresAt time.Time) bool {
+		visited = append(visited, key)
+		c.Delete("c")
+		c.Set("d", 4)
+		return key != "b"
+	})
+	if fmt.Sprint(visited) != "[a b]" {
+		t.Fatalf("Range visited %v", visited)
+	}
+
+	c.Flush()
+	if c.Len() != 0 {
+		t.Fatal("Flush should remove all items")
+	}
+}
+
+func TestTouchAndTTL(t *testing.T) {
+	c := NewCaches[string](time.Minute, 0, nil)
+	if c.Touch("missing", time.Hour) {
+		t.Fatal("Touch on missing key must return false")
+	}
+	c.Set("k", "v")
+	if ttl, ok := c.TTL("k"); !ok || ttl > time.Minute || ttl < 59*time.Second {
+		t.Fatalf("TTL = %v, %v", ttl, ok)
+	}
+	if !c.Touch("k", time.Hour) {
+		t.Fatal("Touch should succeed")
+	}
+	if ttl, _ := c.TTL("k"); ttl < 59*time.Minute {
+		t.Fatalf("TTL after Touch = %v", ttl)
+	}
+	c.Touch("k", NoExpiration)
+	if ttl, _ := c.TTL("k"); ttl != NoExpiration {
+		t.Fatalf("TTL = %v, want NoExpiration", ttl)
+	}
+	if v, _ := c.Get("k"); v != "v" {
+		t.Fatal("Touch must keep the value")
+	}
+}
+
+func TestTouchConcurrentSet(t *testing.T) {
+	c := NewCaches[int](time.Minute, 0, nil)
+	c.Set("k", 0)
+	var wg sync.WaitGroup
+	for i := 1; i <= 100; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			c.Set("k", i)
+		}(i)
+		go func() {
+			defer wg.Done()
+			c.Touch("k", time.Hour)
+		}()
+	}
+	wg.Wait()
+	c.Set("k", 1000)
+	c.Touch("k", time.Hour)
+	if v, _ := c.Get("k"); v != 1000 {
+		t.Fatalf("value = %d, Touch must not restore an old value", v)
+	}
+}
+
+func TestTieredItems(t *testing.T) {
+	c, err := NewTieredCaches[string](time.Minute, 0, nil, TierConfig{Dir: t.TempDir(), MemoryBytes: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < 5; i++ {
+		c.Set(fmt.Sprintf("k%d", i), "value")
+	}
+	if n := c.Len(); n != 5 {
+		t.Fatalf("Len = %d, want entries of both tiers", n)
+	}
+	if n := len(c.Items()); n != 5 {
+		t.Fatalf("Items = %d", n)
+	}
+	if !c.Touch("k0", time.Hour) {
+		t.Fatal("Touch should find key on disk")
+	}
+	if ttl, ok := c.TTL("k0"); !ok || ttl < 59*time.Minute {
+		t.Fatalf("TTL = %v, %v", ttl, ok)
+	}
+	c.Flush()
+	if c.Len() != 0 {
+		t.Fatal("Flush should clear both tiers")
+	}
+}
@@ -0,0 +1,264 @@
+package qcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCircuitOpen 加载器熔断中，未命中缓存的请求直接返回
+var ErrCircuitOpen = errors.New("qcache: loader circuit breaker is open")
+
+// Loader 支持上下文的加载方法
+//
+//	返回值不存在时返回 ok=false, err=nil；返回error视为加载失败，计入熔断
+type Loader[T any] func(ctx context.Context, key string) (value T, ok bool, err error)
+
+// LoaderConfig 加载器配置
+type LoaderConfig struct {
+	Timeout          time.Duration // 单次加载的超时时间，0不限制
+	FailureThreshold int           // 连续失败多少次后熔断，0不启用熔断
+	CoolDown         time.Duration // 熔断持续时间，到期后放行一次试探加载
+}
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 正常
+	BreakerOpen                         // 熔断中
+	BreakerHalfOpen                     // 冷却结束，等待试探加载的结果
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Stats 缓存统计
+type Stats struct {
+	Hits                uint64       // 命中次数
+	Misses              uint64       // 未命中次数
+	Loads               uint64       // 加载器执行次数
+	LoadFailures        uint64       // 加载失败次数，包含超时
+	LoadTimeouts        uint64       // 加载超时次数
+	ShortCircuits       uint64       // 因熔断直接返回的次数
+	Breaker             BreakerState // 熔断器状态
+	ConsecutiveFailures int          // 当前连续失败次数
+	OpenUntil           time.Time    // 熔断结束时间，未熔断时为零值
+}
+
+type cacheStats struct {
+	hits          atomic.Uint64 // 二级缓存的命中次数，未启用时由存储自身计数
+	misses        atomic.Uint64
+	loads         atomic.Uint64
+	loadFailures  atomic.Uint64
+	loadTimeouts  atomic.Uint64
+	shortCircuits atomic.Uint64
+}
+
+// loadCall 一次正在执行的加载，同一key的并发请求共享结果
+type loadCall[T any] struct {
+	done  chan struct{}
+	value T
+	ok    bool
+	err   error
+}
+
+type loader[T any] struct {
+	fn        Loader[T]
+	cfg       LoaderConfig
+	mu        sync.Mutex
+	calls     map[string]*loadCall[T]
+	state     BreakerState
+	failures  int
+	openUntil time.Time
+	probing   bool // 半开状态下是否已有试探加载在执行
+}
+
+// SetLoader 设置支持上下文的加载器，设置后替代创建时传入的findingCallback，需在使用缓存前调用
+//
+//	@param fn 加载方法
+//	@param cfg 超时与熔断配置
+func (c *Caches[T]) SetLoader(fn Loader[T], cfg LoaderConfig) {
+	c.loader = &loader[T]{
+		fn:    fn,
+		cfg:   cfg,
+		calls: make(map[string]*loadCall[T]),
+	}
+}
+
+// GetContext 获取缓存，未命中时使用加载器加载
+//
+//	同一key的并发请求只执行一次加载；ctx只控制本次等待，不会取消其他请求共享的加载
+//	@param ctx
+//	@param key
+//	@return T
+//	@return bool 是否存在
+//	@return error 加载失败、超时、熔断或ctx结束时返回
+func (c *Caches[T]) GetContext(ctx context.Context, key string) (T, bool, error) {
+	var zero T
+	if key == "" {
+		return zero, false, nil
+	}
+	if c.loader == nil {
+		value, ok := c.Get(key)
+		return value, ok, nil
+	}
+	if cached, ok := c.lookup(key); ok {
+		return cached, true, nil
+	}
+	return c.load(ctx, key)
+}
+
+// Stats 获取缓存统计
+//
+//	@return Stats
+func (c *Caches[T]) Stats() Stats {
+	hits, misses := c.caches.lookups()
+	st := Stats{
+		Hits:          hits + c.stats.hits.Load(),
+		Misses:        misses + c.stats.misses.Load(),
+		Loads:         c.stats.loads.Load(),
+		LoadFailures:  c.stats.loadFailures.Load(),
+		LoadTimeouts:  c.stats.loadTimeouts.Load(),
+		ShortCircuits: c.stats.shortCircuits.Load(),
+	}
+	if l := c.loader; l != nil {
+		l.mu.Lock()
+		st.Breaker = l.state
+		st.ConsecutiveFailures = l.failures
+		if l.state == BreakerOpen {
+			st.OpenUntil = l.openUntil
+			if !time.Now().Before(l.openUntil) {
+				st.Breaker = BreakerHalfOpen
+			}
+		}
+		l.mu.Unlock()
+	}
+	return st
+}
+
+// load 执行加载，合并同一key的并发请求
+func (c *Caches[T]) load(ctx context.Context, key string) (T, bool, error) {
+	var zero T
+	l := c.loader
+
+	l.mu.Lock()
+	call, inProgress := l.calls[key]
+	if !inProgress {
+		if err := l.allow(time.Now()); err != nil {
+			l.mu.Unlock()
+			c.stats.shortCircuits.Add(1)
+			return zero, false, err
+		}
+		call = &loadCall[T]{done: make(chan struct{})}
+		l.calls[key] = call
+		go c.runLoad(key, call)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.ok, call.err
+	case <-ctx.Done():
+		return zero, false, ctx.Err()
+	}
+}
+
+// runLoad 在独立的上下文中执行加载，加载方法不响应ctx时也能按超时返回
+func (c *Caches[T]) runLoad(key string, call *loadCall[T]) {
+	l := c.loader
+	ctx := context.Background()
+	cancel := func() {}
+	if l.cfg.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, l.cfg.Timeout)
+	}
+	defer cancel()
+
+	result := make(chan *loadCall[T], 1)
+	go func() {
+		r := &loadCall[T]{}
+		defer func() {
+			if p := recover(); p != nil {
+				r.err = fmt.Errorf("qcache: loader panic: %v", p)
+			}
+			result <- r
+		}()
+		r.value, r.ok, r.err = l.fn(ctx, key)
+	}()
+
+	c.stats.loads.Add(1)
+	select {
+	case r := <-result:
+		call.value, call.ok, call.err = r.value, r.ok, r.err
+	case <-ctx.Done():
+		call.err = fmt.Errorf("qcache: load %s: %w", key, ctx.Err())
+	}
+	if call.err != nil {
+		var zero T
+		call.value, call.ok = zero, false
+		c.stats.loadFailures.Add(1)
+		if errors.Is(call.err, context.DeadlineExceeded) {
+			c.stats.loadTimeouts.Add(1)
+		}
+	} else if call.ok {
+		c.Set(key, call.value)
+	}
+
+	l.mu.Lock()
+	delete(l.calls, key)
+	l.record(call.err, time.Now())
+	l.mu.Unlock()
+	close(call.done)
+}
+
+// allow 判断熔断器是否放行本次加载，调用时需持有mu
+func (l *loader[T]) allow(now time.Time) error {
+	if l.cfg.FailureThreshold <= 0 {
+		return nil
+	}
+	switch l.state {
+	case BreakerOpen:
+		if now.Before(l.openUntil) {
+			return ErrCircuitOpen
+		}
+		l.state = BreakerHalfOpen
+		l.probing = true
+	case BreakerHalfOpen:
+		if l.probing {
+			return ErrCircuitOpen
+		}
+		l.probing = true
+	}
+	return nil
+}
+
+// record 记录加载结果并更新熔断器状态，调用时需持有mu
+func (l *loader[T]) record(err error, now time.Time) {
+	l.probing = false
+	if err == nil {
+		l.failures = 0
+		l.state = BreakerClosed
+		l.openUntil = time.Time{}
+		return
+	}
+	l.failures++
+	if l.cfg.FailureThreshold <= 0 {
+		return
+	}
+	if l.state == BreakerHalfOpen || l.failures >= l.cfg.FailureThreshold {
+		l.state = BreakerOpen
+		l.openUntil = now.Add(l.cfg.CoolDown)
+	}
+}
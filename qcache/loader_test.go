@@ -0,0 +1,127 @@
+package qcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoaderTimeout(t *testing.T) {
+	c := NewCaches[string](time.Minute, 0, nil)
+	block := make(chan struct{})
+	defer close(block)
+	c.SetLoader(func(ctx context.Context, key string) (string, bool, error) {
+		<-block // 不响应ctx的加载方法
+		return "", false, nil
+	}, LoaderConfig{Timeout: 20 * time.Millisecond})
+
+	start := time.Now()
+	_, ok, err := c.GetContext(context.Background(), "k")
+	if ok || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ok=%v err=%v, want deadline exceeded", ok, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("GetContext should return after the load timeout")
+	}
+	if st := c.Stats(); st.LoadTimeouts != 1 || st.LoadFailures != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestLoaderSharesConcurrentLoads(t *testing.T) {
+	c := NewCaches[int](time.Minute, 0, nil)
+	var calls int32
+	c.SetLoader(func(ctx context.Context, key string) (int, bool, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return 42, true, nil
+	}, LoaderConfig{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, ok := c.Get("k"); !ok || v != 42 {
+				t.Errorf("Get = %v, %v", v, ok)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("loader called %d times", n)
+	}
+	if v, ok := c.Get("k"); !ok || v != 42 {
+		t.Fatal("loaded value should be cached")
+	}
+	if st := c.Stats(); st.Hits != 1 || st.Misses != 20 || st.Loads != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestLoaderCircuitBreaker(t *testing.T) {
+	c := NewCaches[int](time.Minute, 0, nil)
+	var fail atomic.Bool
+	fail.Store(true)
+	var calls int32
+	c.SetLoader(func(ctx context.Context, key string) (int, bool, error) {
+		atomic.AddInt32(&calls, 1)
+		if fail.Load() {
+			return 0, false, errors.New("backend down")
+		}
+		return 1, true, nil
+	}, LoaderConfig{FailureThreshold: 3, CoolDown: 50 * time.Millisecond})
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, _, err := c.GetContext(ctx, "k"); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("attempt %d: err = %v", i, err)
+		}
+	}
+	if _, _, err := c.GetContext(ctx, "other"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	st := c.Stats()
+	if st.Breaker != BreakerOpen || st.ShortCircuits != 1 || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("stats = %+v, calls = %d", st, calls)
+	}
+
+	// 冷却后试探失败，重新熔断
+	time.Sleep(60 * time.Millisecond)
+	if st = c.Stats(); st.Breaker != BreakerHalfOpen {
+		t.Fatalf("breaker = %v, want half-open", st.Breaker)
+	}
+	if _, _, err := c.GetContext(ctx, "k"); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("probe err = %v", err)
+	}
+	if _, _, err := c.GetContext(ctx, "k"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen after failed probe", err)
+	}
+
+	// 冷却后试探成功，恢复正常
+	time.Sleep(60 * time.Millisecond)
+	fail.Store(false)
+	if v, ok, err := c.GetContext(ctx, "k"); err != nil || !ok || v != 1 {
+		t.Fatalf("probe = %v, %v, %v", v, ok, err)
+	}
+	if st = c.Stats(); st.Breaker != BreakerClosed || st.ConsecutiveFailures != 0 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestGetContextCancelled(t *testing.T) {
+	c := NewCaches[int](time.Minute, 0, nil)
+	c.SetLoader(func(ctx context.Context, key string) (int, bool, error) {
+		<-ctx.Done()
+		return 0, false, ctx.Err()
+	}, LoaderConfig{Timeout: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := c.GetContext(ctx, "k"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+}
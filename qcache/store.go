@@ -0,0 +1,418 @@
+package qcache
+
+import (
+	"encoding/gob"
+	"github.com/patrickmn/go-cache"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// store 缓存的底层存储
+type store[T any] interface {
+	Set(key string, value T, d time.Duration)
+	Get(key string) (T, bool)
+	GetWithExpiration(key string) (T, time.Time, bool)
+	Delete(key string)
+	// Touch 重新设置已存在条目的有效期，不会用旧值覆盖并发Set的新值，也不会恢复并发删除的条目
+	Touch(key string, d time.Duration) bool
+	Items() map[string]Item[T]
+	Flush()
+	// OnEvicted 设置条目被删除或过期清理时的回调
+	OnEvicted(f func(key string))
+	Save(w io.Writer) error
+	Load(r io.Reader) error
+	// lookup 与Get相同，同时记录命中统计
+	lookup(key string) (T, bool)
+	// lookups 命中与未命中次数
+	lookups() (hits, misses uint64)
+}
+
+// goStore 基于go-cache的存储，值以interface{}保存
+type goStore[T any] struct {
+	c        *cache.Cache
+	segments [goStoreLocks]goSegment // 按key分段，go-cache自身已加锁
+}
+
+// goSegment goStore的一个分段，拥有独立的锁和命中计数
+type goSegment struct {
+	mu     sync.Mutex // 串行化同一key的Set与Touch
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	_      [40]byte // 填充，避免相邻分段的计数落在同一缓存行
+}
+
+// goStoreLocks goStore的分段数量
+const goStoreLocks = 16
+
+func newGoStore[T any](defaultExpiration, cleanupInterval time.Duration) *goStore[T] {
+	return &goStore[T]{c: cache.New(defaultExpiration, cleanupInterval)}
+}
+
+func (s *goStore[T]) Set(key string, value T, d time.Duration) {
+	mu := &s.segment(key).mu
+	mu.Lock()
+	s.c.Set(key, value, d)
+	mu.Unlock()
+}
+
+func (s *goStore[T]) Get(key string) (T, bool) {
+	value, ok := s.c.Get(key)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	// 安全的类型断言
+	typedValue, ok := value.(T)
+	return typedValue, ok
+}
+
+func (s *goStore[T]) GetWithExpiration(key string) (T, time.Time, bool) {
+	value, expiration, ok := s.c.GetWithExpiration(key)
+	if !ok {
+		var zero T
+		return zero, time.Time{}, false
+	}
+	typedValue, ok := value.(T)
+	return typedValue, expiration, ok
+}
+
+func (s *goStore[T]) segment(key string) *goSegment {
+	return &s.segments[fnv32(key)%goStoreLocks]
+}
+
+func (s *goStore[T]) lookup(key string) (T, bool) {
+	value, ok := s.Get(key)
+	if seg := s.segment(key); ok {
+		seg.hits.Add(1)
+	} else {
+		seg.misses.Add(1)
+	}
+	return value, ok
+}
+
+func (s *goStore[T]) lookups() (uint64, uint64) {
+	var hits, misses uint64
+	for i := range s.segments {
+		hits += s.segments[i].hits.Load()
+		misses += s.segments[i].misses.Load()
+	}
+	return hits, misses
+}
+
+func (s *goStore[T]) Delete(key string) {
+	s.c.Delete(key)
+}
+
+// Touch 与同一key的Set互斥；并发Delete或Flush后Replace失败，不会恢复已删除的条目
+func (s *goStore[T]) Touch(key string, d time.Duration) bool {
+	mu := &s.segment(key).mu
+	mu.Lock()
+	defer mu.Unlock()
+	value, ok := s.c.Get(key)
+	if !ok {
+		return false
+	}
+	return s.c.Replace(key, value, d) == nil
+}
+
+func (s *goStore[T]) Items() map[string]Item[T] {
+	items := s.c.Items()
+	result := make(map[string]Item[T], len(items))
+	for k, item := range items {
+		value, ok := item.Object.(T)
+		if !ok {
+			continue
+		}
+		result[k] = Item[T]{Value: value, ExpiresAt: expiresAt(item.Expiration)}
+	}
+	return result
+}
+
+func (s *goStore[T]) Flush() {
+	s.c.Flush()
+}
+
+func (s *goStore[T]) OnEvicted(f func(key string)) {
+	s.c.OnEvicted(func(key string, _ interface{}) {
+		f(key)
+	})
+}
+
+func (s *goStore[T]) Save(w io.Writer) error {
+	return s.c.Save(w)
+}
+
+func (s *goStore[T]) Load(r io.Reader) error {
+	return s.c.Load(r)
+}
+
+// entry 分片存储中的条目，值按类型保存，避免interface{}装箱
+type entry[T any] struct {
+	value      T
+	expiration int64 // 过期时间 UnixNano，0表示永不过期
+}
+
+// shard 一个分片，拥有独立的读写锁和命中计数
+type shard[T any] struct {
+	mu     sync.RWMutex
+	items  map[string]entry[T]
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	_      [24]byte // 填充，避免相邻分片的锁落在同一缓存行
+}
+
+func (sh *shard[T]) get(key string) (T, bool) {
+	sh.mu.RLock()
+	e, ok := sh.items[key]
+	sh.mu.RUnlock()
+	if !ok || (e.expiration > 0 && time.Now().UnixNano() > e.expiration) {
+		var zero T
+		return zero, false
+	}
+	return e.value, true
+}
+
+// shardedStore 按key哈希分片的存储
+type shardedStore[T any] struct {
+	shards            []*shard[T]
+	mask              uint32
+	defaultExpiration time.Duration
+	onEvicted         atomic.Pointer[func(key string)]
+	stop              chan struct{}
+	stopOnce          sync.Once
+}
+
+// persistItem SaveToFile保存的条目格式
+type persistItem[T any] struct {
+	Object     T
+	Expiration int64
+}
+
+func newShardedStore[T any](shards int, defaultExpiration, cleanupInterval time.Duration) *shardedStore[T] {
+	// 分片数取不小于shards的2的幂，便于用掩码定位分片
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	if defaultExpiration == 0 {
+		defaultExpiration = NoExpiration
+	}
+	s := &shardedStore[T]{
+		shards:            make([]*shard[T], n),
+		mask:              uint32(n - 1),
+		defaultExpiration: defaultExpiration,
+		stop:              make(chan struct{}),
+	}
+	for i := range s.shards {
+		s.shards[i] = &shard[T]{items: make(map[string]entry[T])}
+	}
+	if cleanupInterval > 0 {
+		go s.janitor(cleanupInterval)
+	}
+	return s
+}
+
+func (s *shardedStore[T]) shardOf(key string) *shard[T] {
+	return s.shards[fnv32(key)&s.mask]
+}
+
+func (s *shardedStore[T]) expiration(d time.Duration) int64 {
+	if d == DefaultExpiration {
+		d = s.defaultExpiration
+	}
+	if d > 0 {
+		return time.Now().Add(d).UnixNano()
+	}
+	return 0
+}
+
+func (s *shardedStore[T]) Set(key string, value T, d time.Duration) {
+	e := entry[T]{value: value, expiration: s.expiration(d)}
+	sh := s.shardOf(key)
+	sh.mu.Lock()
+	sh.items[key] = e
+	sh.mu.Unlock()
+}
+
+func (s *shardedStore[T]) Get(key string) (T, bool) {
+	return s.shardOf(key).get(key)
+}
+
+// lookup 命中统计计入key所在的分片，不需要再计算一次哈希
+func (s *shardedStore[T]) lookup(key string) (T, bool) {
+	sh := s.shardOf(key)
+	value, ok := sh.get(key)
+	if ok {
+		sh.hits.Add(1)
+	} else {
+		sh.misses.Add(1)
+	}
+	return value, ok
+}
+
+func (s *shardedStore[T]) lookups() (uint64, uint64) {
+	var hits, misses uint64
+	for _, sh := range s.shards {
+		hits += sh.hits.Load()
+		misses += sh.misses.Load()
+	}
+	return hits, misses
+}
+
+func (s *shardedStore[T]) GetWithExpiration(key string) (T, time.Time, bool) {
+	sh := s.shardOf(key)
+	sh.mu.RLock()
+	e, ok := sh.items[key]
+	sh.mu.RUnlock()
+	if !ok || (e.expiration > 0 && time.Now().UnixNano() > e.expiration) {
+		var zero T
+		return zero, time.Time{}, false
+	}
+	return e.value, expiresAt(e.expiration), true
+}
+
+func (s *shardedStore[T]) Delete(key string) {
+	sh := s.shardOf(key)
+	sh.mu.Lock()
+	_, ok := sh.items[key]
+	delete(sh.items, key)
+	sh.mu.Unlock()
+	if f := s.onEvicted.Load(); ok && f != nil {
+		(*f)(key)
+	}
+}
+
+func (s *shardedStore[T]) Touch(key string, d time.Duration) bool {
+	sh := s.shardOf(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	e, ok := sh.items[key]
+	if !ok || (e.expiration > 0 && time.Now().UnixNano() > e.expiration) {
+		return false
+	}
+	e.expiration = s.expiration(d)
+	sh.items[key] = e
+	return true
+}
+
+func (s *shardedStore[T]) Items() map[string]Item[T] {
+	now := time.Now().UnixNano()
+	result := make(map[string]Item[T])
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for k, e := range sh.items {
+			if e.expiration > 0 && now > e.expiration {
+				continue
+			}
+			result[k] = Item[T]{Value: e.value, ExpiresAt: expiresAt(e.expiration)}
+		}
+		sh.mu.RUnlock()
+	}
+	return result
+}
+
+func (s *shardedStore[T]) Flush() {
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		sh.items = make(map[string]entry[T])
+		sh.mu.Unlock()
+	}
+}
+
+func (s *shardedStore[T]) OnEvicted(f func(key string)) {
+	s.onEvicted.Store(&f)
+}
+
+func (s *shardedStore[T]) Save(w io.Writer) error {
+	items := make(map[string]persistItem[T])
+	for k, item := range s.Items() {
+		var expiration int64
+		if !item.ExpiresAt.IsZero() {
+			expiration = item.ExpiresAt.UnixNano()
+		}
+		items[k] = persistItem[T]{Object: item.Value, Expiration: expiration}
+	}
+	return gob.NewEncoder(w).Encode(items)
+}
+
+// Load 从Save保存的数据中加载，与go-cache一致，不覆盖已存在且未过期的条目
+func (s *shardedStore[T]) Load(r io.Reader) error {
+	items := map[string]persistItem[T]{}
+	if err := gob.NewDecoder(r).Decode(&items); err != nil {
+		return err
+	}
+	now := time.Now().UnixNano()
+	for k, item := range items {
+		if item.Expiration > 0 && now > item.Expiration {
+			continue
+		}
+		sh := s.shardOf(k)
+		sh.mu.Lock()
+		old, exist := sh.items[k]
+		if !exist || (old.expiration > 0 && now > old.expiration) {
+			sh.items[k] = entry[T]{value: item.Object, expiration: item.Expiration}
+		}
+		sh.mu.Unlock()
+	}
+	return nil
+}
+
+// janitor 定期清理过期条目
+func (s *shardedStore[T]) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *shardedStore[T]) deleteExpired() {
+	now := time.Now().UnixNano()
+	for _, sh := range s.shards {
+		var evicted []string
+		sh.mu.Lock()
+		for k, e := range sh.items {
+			if e.expiration > 0 && now > e.expiration {
+				delete(sh.items, k)
+				evicted = append(evicted, k)
+			}
+		}
+		sh.mu.Unlock()
+		if f := s.onEvicted.Load(); f != nil {
+			for _, k := range evicted {
+				(*f)(k)
+			}
+		}
+	}
+}
+
+func (s *shardedStore[T]) stopJanitor() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// expiresAt 将UnixNano过期时间转为time.Time，0对应零值
+func expiresAt(expiration int64) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, expiration)
+}
+
+// fnv32 计算key的FNV-1a哈希，不产生内存分配
+func fnv32(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return hash
+}
@@ -0,0 +1,94 @@
+package qcache
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShardedCaches(t *testing.T) {
+	c := NewShardedCaches[int](8, time.Minute, 0, nil)
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprintf("k%d", i), i)
+	}
+	if c.Len() != 100 {
+		t.Fatalf("Len = %d", c.Len())
+	}
+	if v, ok := c.Get("k42"); !ok || v != 42 {
+		t.Fatalf("Get = %v, %v", v, ok)
+	}
+	c.Delete("k42")
+	if _, ok := c.Get("k42"); ok {
+		t.Fatal("k42 should be deleted")
+	}
+
+	c.SetWithNewExpiration("short", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Fatal("expired item must not be returned")
+	}
+	if ttl, ok := c.TTL("k1"); !ok || ttl > time.Minute {
+		t.Fatalf("TTL = %v, %v", ttl, ok)
+	}
+	if !c.Touch("k1", NoExpiration) {
+		t.Fatal("Touch should succeed")
+	}
+	if ttl, _ := c.TTL("k1"); ttl != NoExpiration {
+		t.Fatalf("TTL = %v, want NoExpiration", ttl)
+	}
+
+	path := filepath.Join(t.TempDir(), "cache.gob")
+	if err := c.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewShardedCaches[int](0, time.Minute, 0, nil)
+	if err := loaded.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 99 {
+		t.Fatalf("loaded Len = %d", loaded.Len())
+	}
+	if ttl, _ := loaded.TTL("k1"); ttl != NoExpiration {
+		t.Fatalf("loaded TTL = %v", ttl)
+	}
+
+	c.Flush()
+	if c.Len() != 0 {
+		t.Fatal("Flush should remove all items")
+	}
+}
+
+func TestShardedCachesJanitor(t *testing.T) {
+	c := NewShardedCaches[string](4, 5*time.Millisecond, 5*time.Millisecond, nil)
+	s := c.caches.(*shardedStore[string])
+	evicted := make(chan string, 1)
+	s.OnEvicted(func(key string) {
+		evicted <- key
+	})
+	c.Set("k", "v")
+	select {
+	case key := <-evicted:
+		if key != "k" {
+			t.Fatalf("evicted %q", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not remove the expired item")
+	}
+	s.stopJanitor()
+}
+
+func TestShardedCachesCallback(t *testing.T) {
+	c := NewShardedCaches[string](0, time.Minute, 0, func(key string) (string, bool) {
+		return "found:" + key, true
+	})
+	if v, ok := c.Get("a"); !ok || v != "found:a" {
+		t.Fatalf("Get = %v, %v", v, ok)
+	}
+	if st := c.Stats(); st.Misses != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("callback result should be cached")
+	}
+}
@@ -0,0 +1,232 @@
+package qcache
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"github.com/patrickmn/go-cache"
+	"sync"
+	"time"
+)
+
+// TierConfig 二级缓存配置
+type TierConfig struct {
+	Dir         string // 磁盘缓存目录
+	MemoryBytes int64  // 内存层字节预算，超出后将最久未使用的条目溢出到磁盘
+	DiskBytes   int64  // 磁盘层字节预算，超出后丢弃最早写入的条目，0不限制
+}
+
+// memTier 内存层的LRU记录，用于按字节预算溢出
+type memTier struct {
+	mu      sync.Mutex
+	budget  int64
+	bytes   int64
+	lru     *list.List // 最近使用的在前
+	index   map[string]*list.Element
+	evictMu sync.Mutex
+	evicted []string // go-cache删除或过期的key，在持有mu时统一处理
+}
+
+type memEntry struct {
+	key  string
+	size int64
+}
+
+// NewTieredCaches 创建带磁盘二级缓存的缓存，内存超出预算的条目溢出到磁盘，Get时再提升回内存
+//
+//	@param defaultExpiration 缓存项的默认过期时间
+//	@param cleanupInterval 清理过期缓存项的时间间隔 0不清理 非0间隔清理
+//	@param findingCallback Get缓存不存在时，主动查找回调方法
+//	@param cfg 二级缓存配置
+//	@return *Caches[T]
+//	@return error
+func NewTieredCaches[T any](defaultExpiration, cleanupInterval time.Duration, findingCallback func(key string) (T, bool), cfg TierConfig) (*Caches[T], error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("tier dir is empty")
+	}
+	if cfg.MemoryBytes <= 0 {
+		return nil, errors.New("tier memory budget must be positive")
+	}
+	disk, err := openDiskStore(cfg.Dir, cfg.DiskBytes)
+	if err != nil {
+		return nil, err
+	}
+	c := NewCaches[T](defaultExpiration, cleanupInterval, findingCallback)
+	c.disk = disk
+	c.tier = &memTier{
+		budget: cfg.MemoryBytes,
+		lru:    list.New(),
+		index:  make(map[string]*list.Element),
+	}
+	c.caches.OnEvicted(func(key string) {
+		c.tier.evictMu.Lock()
+		c.tier.evicted = append(c.tier.evicted, key)
+		c.tier.evictMu.Unlock()
+	})
+	return c, nil
+}
+
+// Close 将内存层的条目全部写入磁盘并关闭磁盘文件，未启用二级缓存时无操作
+//
+//	@return error
+func (c *Caches[T]) Close() error {
+	if c.disk == nil {
+		return nil
+	}
+	c.tier.mu.Lock()
+	defer c.tier.mu.Unlock()
+	c.spillAll()
+	return c.disk.close()
+}
+
+// setTiered 写入内存层，并在超出预算时溢出
+func (c *Caches[T]) setTiered(key string, value T, d time.Duration) {
+	size := int64(len(key))
+	if data, err := json.Marshal(value); err == nil {
+		size += int64(len(data))
+	}
+
+	c.tier.mu.Lock()
+	defer c.tier.mu.Unlock()
+	c.caches.Set(key, value, d)
+	c.disk.delete(key) // 新值覆盖磁盘中的旧值
+	c.tier.track(key, size)
+	c.spill()
+}
+
+// deleteTiered 从内存层和磁盘层同时删除
+func (c *Caches[T]) deleteTiered(key string) {
+	c.tier.mu.Lock()
+	defer c.tier.mu.Unlock()
+	c.caches.Delete(key)
+	c.tier.forget(key)
+	c.drainEvicted()
+	c.disk.delete(key)
+}
+
+// touchTiered 标记内存中的条目为最近使用
+func (c *Caches[T]) touchTiered(key string) {
+	c.tier.mu.Lock()
+	defer c.tier.mu.Unlock()
+	if elem, ok := c.tier.index[key]; ok {
+		c.tier.lru.MoveToFront(elem)
+	}
+}
+
+// promote 从磁盘层读取并提升回内存层
+func (c *Caches[T]) promote(key string) (T, bool) {
+	c.tier.mu.Lock()
+	defer c.tier.mu.Unlock()
+	return c.promoteLocked(key)
+}
+
+// promoteLocked 从磁盘层读取并提升回内存层，调用时需持有tier.mu
+func (c *Caches[T]) promoteLocked(key string) (T, bool) {
+	var zero T
+
+	// 可能已被其他goroutine提升
+	if value, ok := c.caches.Get(key); ok {
+		return value, true
+	}
+
+	data, expiresAt, ok := c.disk.get(key)
+	if !ok {
+		return zero, false
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		c.disk.delete(key)
+		return zero, false
+	}
+	d := cache.NoExpiration
+	if expiresAt > 0 {
+		d = time.Until(time.Unix(0, expiresAt))
+		if d <= 0 {
+			c.disk.delete(key)
+			return zero, false
+		}
+	}
+	c.caches.Set(key, value, d)
+	c.disk.delete(key)
+	c.tier.track(key, int64(len(key)+len(data)))
+	c.spill()
+	return value, true
+}
+
+// spill 将最久未使用的条目写入磁盘，直到内存层回到预算内，调用时需持有tier.mu
+func (c *Caches[T]) spill() {
+	c.drainEvicted()
+	for c.tier.bytes > c.tier.budget && c.tier.lru.Len() > 1 {
+		c.spillOldest()
+	}
+	c.drainEvicted()
+}
+
+// spillAll 将内存层的全部条目写入磁盘，调用时需持有tier.mu
+func (c *Caches[T]) spillAll() {
+	c.drainEvicted()
+	for c.tier.lru.Len() > 0 {
+		c.spillOldest()
+	}
+	c.drainEvicted()
+}
+
+// spillOldest 将最久未使用的一个条目写入磁盘，调用时需持有tier.mu
+func (c *Caches[T]) spillOldest() {
+	entry := c.tier.lru.Back().Value.(*memEntry)
+	c.tier.forget(entry.key)
+
+	value, expiration, ok := c.caches.GetWithExpiration(entry.key)
+	c.caches.Delete(entry.key)
+	if !ok {
+		return // 已过期的条目直接丢弃
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	var expiresAt int64
+	if !expiration.IsZero() {
+		expiresAt = expiration.UnixNano()
+	}
+	_ = c.disk.put(entry.key, data, expiresAt)
+}
+
+// drainEvicted 处理go-cache删除或过期的key，调用时需持有tier.mu
+func (c *Caches[T]) drainEvicted() {
+	c.tier.evictMu.Lock()
+	keys := c.tier.evicted
+	c.tier.evicted = nil
+	c.tier.evictMu.Unlock()
+
+	for _, key := range keys {
+		// 删除后又被重新写入的key继续保留
+		if _, ok := c.caches.Get(key); ok {
+			continue
+		}
+		c.tier.forget(key)
+	}
+}
+
+func (t *memTier) track(key string, size int64) {
+	t.forget(key)
+	t.index[key] = t.lru.PushFront(&memEntry{key: key, size: size})
+	t.bytes += size
+}
+
+func (t *memTier) forget(key string) {
+	if elem, ok := t.index[key]; ok {
+		t.bytes -= elem.Value.(*memEntry).size
+		t.lru.Remove(elem)
+		delete(t.index, key)
+	}
+}
+
+func (t *memTier) reset() {
+	t.lru.Init()
+	t.index = make(map[string]*list.Element)
+	t.bytes = 0
+	t.evictMu.Lock()
+	t.evicted = nil
+	t.evictMu.Unlock()
+}
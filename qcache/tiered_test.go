@@ -0,0 +1,190 @@
+package qcache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTieredSpillAndPromote(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewTieredCaches[string](time.Minute, 0, nil, TierConfig{Dir: dir, MemoryBytes: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		c.Set(fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i))
+	}
+	if c.tier.bytes > 64 {
+		t.Fatalf("memory tier over budget: %d", c.tier.bytes)
+	}
+	if _, ok := c.caches.Get("key0"); ok {
+		t.Fatal("key0 should have been spilled to disk")
+	}
+	if v, ok := c.Get("key0"); !ok || v != "value0" {
+		t.Fatalf("promote key0 = %q, %v", v, ok)
+	}
+	if _, ok := c.caches.Get("key0"); !ok {
+		t.Fatal("key0 should be back in memory")
+	}
+
+	c.Delete("key1")
+	if _, ok := c.Get("key1"); ok {
+		t.Fatal("key1 should be deleted from both tiers")
+	}
+	if st := c.Stats(); st.Hits != 1 || st.Misses != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestTieredKeysDedupe(t *testing.T) {
+	c, err := NewTieredCaches[string](time.Minute, 0, nil, TierConfig{Dir: t.TempDir(), MemoryBytes: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		c.Set(fmt.Sprintf("key%d", i), "v")
+	}
+	// 模拟提升过程中同一个key同时存在于两层
+	c.Set("key9", "v")
+	_ = c.disk.put("key9", []byte(`"v"`), 0)
+	if n := c.Len(); n != 10 {
+		t.Fatalf("Len = %d", n)
+	}
+	if keys := c.Keys(); len(keys) != 10 || keys[9] != "key9" {
+		t.Fatalf("Keys = %v", keys)
+	}
+	if n := len(c.Items()); n != 10 {
+		t.Fatalf("Items = %d", n)
+	}
+}
+
+func TestTieredRecoverAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewTieredCaches[int](time.Minute, 0, nil, TierConfig{Dir: dir, MemoryBytes: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		c.Set(fmt.Sprintf("k%d", i), i)
+	}
+	c.Delete("k1")
+	_ = c.Close()
+
+	// 模拟异常退出时写了一半的记录
+	f, err := os.OpenFile(filepath.Join(dir, diskFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write(encodeRecord(opPut, "torn", []byte("123"), 0)[:10])
+	_ = f.Close()
+
+	c, err = NewTieredCaches[int](time.Minute, 0, nil, TierConfig{Dir: dir, MemoryBytes: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if v, ok := c.Get("k0"); !ok || v != 0 {
+		t.Fatalf("k0 = %v, %v", v, ok)
+	}
+	if _, ok := c.Get("k1"); ok {
+		t.Fatal("deleted key must not be recovered")
+	}
+	if _, ok := c.Get("torn"); ok {
+		t.Fatal("torn record must be discarded")
+	}
+}
+
+func TestTieredDiskBudget(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewTieredCaches[string](time.Minute, 0, nil, TierConfig{Dir: dir, MemoryBytes: 1, DiskBytes: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 50; i++ {
+		c.Set(fmt.Sprintf("key%d", i), "some value")
+	}
+	if n := c.disk.bytes(); n > 200 {
+		t.Fatalf("disk tier over budget: %d", n)
+	}
+	if _, ok := c.Get("key0"); ok {
+		t.Fatal("oldest entry should have been dropped")
+	}
+}
+
+func TestDiskStoreLowWater(t *testing.T) {
+	d, err := openDiskStore(t.TempDir(), 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.close()
+
+	value := make([]byte, 80)
+	var evictions, compactions int
+	for i := 0; i < 1000; i++ {
+		count, size := d.order.Len(), d.size
+		if err = d.put(fmt.Sprintf("key%03d", i), value, 0); err != nil {
+			t.Fatal(err)
+		}
+		if d.order.Len() <= count {
+			evictions++
+			if d.live > 9000 {
+				t.Fatalf("live after eviction = %d", d.live)
+			}
+		}
+		if d.size < size {
+			compactions++
+		}
+		if d.live > 10000 || d.size > 2*10000+200 {
+			t.Fatalf("live = %d, size = %d", d.live, d.size)
+		}
+	}
+	// 丢弃到低水位后可以再写入多条，日志在失效记录累积后才压缩
+	if evictions > 1000/5 || compactions > 1000/50 {
+		t.Fatalf("evictions = %d, compactions = %d", evictions, compactions)
+	}
+}
+
+func TestDiskStoreTombstonesSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	d, err := openDiskStore(dir, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 写入少量记录只触发淘汰不触发压缩，删除标记必须留在日志中
+	value := make([]byte, 80)
+	for i := 0; i < 10; i++ {
+		if err = d.put(fmt.Sprintf("key%02d", i), value, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := d.index["key00"]; ok {
+		t.Fatal("key00 not evicted")
+	}
+	// 已被淘汰的key再次删除同样需要写入删除标记
+	d.delete("key00")
+	d.delete("key09")
+	_ = d.close()
+
+	d, err = openDiskStore(dir, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.close()
+	for _, key := range []string{"key00", "key01", "key09"} {
+		if _, ok := d.index[key]; ok {
+			t.Fatalf("%s recovered after restart", key)
+		}
+	}
+	if _, ok := d.index["key08"]; !ok {
+		t.Fatal("key18 lost after restart")
+	}
+}
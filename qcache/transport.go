@@ -0,0 +1,165 @@
+package qcache
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+const maxMessageSize = 64 * 1024
+
+var socketSeq int64
+
+// unixTransport 基于Unix域数据报套接字的传输层
+//
+//	每个实例在共享目录下绑定一个套接字文件，Publish时逐个发送给目录下的其他套接字
+type unixTransport struct {
+	dir       string
+	path      string
+	conn      *net.UnixConn
+	closeOnce sync.Once
+}
+
+// NewUnixTransport 创建基于Unix域套接字的传输层
+//
+//	@param dir 共享目录，同一主机上需要互相通知的进程使用同一个目录
+//	@return Transport
+//	@return error
+func NewUnixTransport(dir string) (Transport, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%d-%d.sock", os.Getpid(), atomic.AddInt64(&socketSeq, 1))
+	path := filepath.Join(dir, name)
+	_ = os.Remove(path)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &unixTransport{dir: dir, path: path, conn: conn}, nil
+}
+
+func (t *unixTransport) Publish(msg []byte) error {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sock") {
+			continue
+		}
+		peer := filepath.Join(t.dir, entry.Name())
+		if peer == t.path {
+			continue
+		}
+		_, err = t.conn.WriteToUnix(msg, &net.UnixAddr{Name: peer, Net: "unixgram"})
+		if err != nil {
+			// 对方进程已退出，清理残留的套接字文件
+			if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENOENT) {
+				_ = os.Remove(peer)
+				continue
+			}
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (t *unixTransport) Subscribe(handler func(msg []byte)) error {
+	go func() {
+		buf := make([]byte, maxMessageSize)
+		for {
+			n, _, err := t.conn.ReadFromUnix(buf)
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				continue
+			}
+			msg := make([]byte, n)
+			copy(msg, buf[:n])
+			handler(msg)
+		}
+	}()
+	return nil
+}
+
+func (t *unixTransport) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		err = t.conn.Close()
+		_ = os.Remove(t.path)
+	})
+	return err
+}
+
+// multicastTransport 基于UDP组播的传输层
+type multicastTransport struct {
+	listen *net.UDPConn
+	send   *net.UDPConn
+}
+
+// NewMulticastTransport 创建基于UDP组播的传输层
+//
+//	@param addr 组播地址，如 239.0.0.1:9999
+//	@return Transport
+//	@return error
+func NewMulticastTransport(addr string) (Transport, error) {
+	groupAddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	listen, err := net.ListenMulticastUDP("udp4", nil, groupAddr)
+	if err != nil {
+		return nil, err
+	}
+	send, err := net.DialUDP("udp4", nil, groupAddr)
+	if err != nil {
+		_ = listen.Close()
+		return nil, err
+	}
+	return &multicastTransport{listen: listen, send: send}, nil
+}
+
+func (t *multicastTransport) Publish(msg []byte) error {
+	_, err := t.send.Write(msg)
+	return err
+}
+
+func (t *multicastTransport) Subscribe(handler func(msg []byte)) error {
+	go func() {
+		buf := make([]byte, maxMessageSize)
+		for {
+			n, _, err := t.listen.ReadFromUDP(buf)
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				continue
+			}
+			msg := make([]byte, n)
+			copy(msg, buf[:n])
+			handler(msg)
+		}
+	}()
+	return nil
+}
+
+func (t *multicastTransport) Close() error {
+	err1 := t.send.Close()
+	err2 := t.listen.Close()
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
@@ -0,0 +1,26 @@
+package qlauncher
+
+import (
+	"github.com/kardianos/service"
+)
+
+// Backend 系统服务后端，负责创建与操作系统服务管理器对接的服务
+//
+//	默认使用 kardianos/service，测试时可替换为不接触系统服务管理器的实现
+type Backend interface {
+	// New 创建服务，服务运行时应依次调用 i.Start、等待退出、调用 i.Stop
+	New(i service.Interface, c *service.Config) (service.Service, error)
+	// Platform 服务管理器类型，如 linux-systemd、windows-service
+	Platform() string
+}
+
+// SystemBackend 基于 kardianos/service 的系统服务后端
+type SystemBackend struct{}
+
+func (SystemBackend) New(i service.Interface, c *service.Config) (service.Service, error) {
+	return service.New(i, c)
+}
+
+func (SystemBackend) Platform() string {
+	return service.Platform()
+}
@@ -0,0 +1,221 @@
+package qlauncher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultCrashMaxFiles 默认保留的崩溃报告数量
+	DefaultCrashMaxFiles = 20
+	// DefaultCrashLogLines 默认记录的最近日志行数
+	DefaultCrashLogLines = 200
+)
+
+// CrashReporter 崩溃报告，panic时将调用栈、构建信息、配置快照和最近的日志写入文件
+//
+//	报告文件名为 crash-<时间>-<进程号>-<序号>.log
+type CrashReporter struct {
+	Dir      string        // 报告目录
+	MaxFiles int           // 最多保留的报告数量，0使用DefaultCrashMaxFiles
+	MaxAge   time.Duration // 报告最长保留时间，0不限制
+	LogLines int           // 记录最近的日志行数，0使用DefaultCrashLogLines
+	// Snapshot 获取配置快照，结果以JSON写入报告，可为nil
+	Snapshot func() any
+
+	once sync.Once
+	ring *lineRing
+	seq  atomic.Int64
+}
+
+// NewCrashReporter 创建崩溃报告
+//
+//	@param dir 报告目录
+//	@return *CrashReporter
+func NewCrashReporter(dir string) *CrashReporter {
+	return &CrashReporter{Dir: dir}
+}
+
+// CaptureLog 开始记录标准库log输出的最近日志，原输出不受影响
+//
+//	@return func() 恢复原输出
+func (c *CrashReporter) CaptureLog() func() {
+	ring := c.logRing()
+	prev := log.Writer()
+	log.SetOutput(io.MultiWriter(prev, ring))
+	return func() {
+		log.SetOutput(prev)
+	}
+}
+
+// Report 写入崩溃报告
+//
+//	@param source 发生panic的位置，如 start、stop、worker:consumer
+//	@param value recover得到的值
+//	@param stack panic所在goroutine的调用栈，为空时只记录所有goroutine的调用栈
+//	@return string 报告文件路径
+//	@return error
+func (c *CrashReporter) Report(source string, value any, stack []byte) (string, error) {
+	if err := os.MkdirAll(c.Dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	// 序号避免同一毫秒内多次panic时覆盖，并保证文件名按时间排序
+	seq := c.seq.Add(1)
+	file := filepath.Join(c.Dir, fmt.Sprintf("crash-%s-%d-%04d.log", now.Format("20060102-150405.000"), os.Getpid(), seq%10000))
+
+	var b bytes.Buffer
+	b.WriteString("=== Crash ===\n")
+	fmt.Fprintf(&b, "Time: %s\n", now.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "Source: %s\n", source)
+	fmt.Fprintf(&b, "Panic: %v\n", value)
+	if len(stack) > 0 {
+		b.WriteString("\n=== Stack ===\n")
+		b.Write(stack)
+	}
+
+	b.WriteString("\n=== Build ===\n")
+	fmt.Fprintf(&b, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "PID: %d\n", os.Getpid())
+	host, _ := os.Hostname()
+	fmt.Fprintf(&b, "Host: %s\n", host)
+	fmt.Fprintf(&b, "Args: %s\n", strings.Join(os.Args, " "))
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&b, "Module: %s %s\n", info.Main.Path, info.Main.Version)
+		for _, s := range info.Settings {
+			if strings.HasPrefix(s.Key, "vcs.") {
+				fmt.Fprintf(&b, "%s: %s\n", s.Key, s.Value)
+			}
+		}
+	}
+
+	if c.Snapshot != nil {
+		b.WriteString("\n=== Config ===\n")
+		if data, err := json.MarshalIndent(c.Snapshot(), "", "  "); err != nil {
+			fmt.Fprintf(&b, "snapshot error: %s\n", err)
+		} else {
+			b.Write(data)
+			b.WriteString("\n")
+		}
+	}
+
+	if lines := c.logRing().lines(); len(lines) > 0 {
+		b.WriteString("\n=== Log ===\n")
+		for _, line := range lines {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n=== Goroutines ===\n")
+	b.Write(allStacks())
+
+	if err := os.WriteFile(file, b.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	c.prune()
+	return file, nil
+}
+
+// Files 获取现有的崩溃报告，按时间从旧到新排列
+//
+//	@return []string
+func (c *CrashReporter) Files() []string {
+	files, _ := filepath.Glob(filepath.Join(c.Dir, "crash-*.log"))
+	// 文件名中的时间可按字典序排序
+	sort.Strings(files)
+	return files
+}
+
+// prune 按数量和时间清理旧报告
+func (c *CrashReporter) prune() {
+	maxFiles := c.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = DefaultCrashMaxFiles
+	}
+	files := c.Files()
+	for i, file := range files {
+		remove := i < len(files)-maxFiles
+		if !remove && c.MaxAge > 0 {
+			if st, err := os.Stat(file); err == nil && time.Since(st.ModTime()) > c.MaxAge {
+				remove = true
+			}
+		}
+		if remove {
+			_ = os.Remove(file)
+		}
+	}
+}
+
+func (c *CrashReporter) logRing() *lineRing {
+	c.once.Do(func() {
+		n := c.LogLines
+		if n <= 0 {
+			n = DefaultCrashLogLines
+		}
+		c.ring = &lineRing{buf: make([]string, n)}
+	})
+	return c.ring
+}
+
+// allStacks 获取所有goroutine的调用栈
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 16<<20 {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// lineRing 保存最近N行日志
+type lineRing struct {
+	mu      sync.Mutex
+	buf     []string
+	next    int
+	full    bool
+	partial []byte // 尚未遇到换行的内容
+}
+
+func (r *lineRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data := append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		r.buf[r.next] = string(data[:i])
+		r.next = (r.next + 1) % len(r.buf)
+		if r.next == 0 {
+			r.full = true
+		}
+		data = data[i+1:]
+	}
+	r.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+func (r *lineRing) lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]string(nil), r.buf[:r.next]...)
+	}
+	return append(append([]string(nil), r.buf[r.next:]...), r.buf[:r.next]...)
+}
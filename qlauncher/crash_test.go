@@ -0,0 +1,130 @@
+package qlauncher
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCrashReport(t *testing.T) {
+	c := NewCrashReporter(t.TempDir())
+	c.LogLines = 2
+	c.Snapshot = func() any {
+		return Options{Name: "demo"}
+	}
+	restore := c.CaptureLog()
+	log.Println("line 1")
+	log.Println("line 2")
+	log.Println("line 3")
+	restore()
+
+	file, err := c.Report("start", "boom", []byte("goroutine 1 [running]:\nmain.start()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, s := range []string{"Source: start\n", "Panic: boom\n", "main.start()", "Go: go", `"Name": "demo"`, "line 2\n", "line 3\n", "=== Goroutines ===", "TestCrashReport"} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("missing %q in\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "line 1") {
+		t.Fatal("only the last 2 log lines should be kept")
+	}
+}
+
+func TestCrashRetention(t *testing.T) {
+	c := NewCrashReporter(t.TempDir())
+	c.MaxFiles = 3
+	for i := 0; i < 5; i++ {
+		if _, err := c.Report("test", i, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := c.Files()
+	if len(files) != 3 {
+		t.Fatalf("files = %v", files)
+	}
+	// 保留最新的报告
+	data, _ := os.ReadFile(files[2])
+	if !strings.Contains(string(data), "Panic: 4\n") {
+		t.Fatalf("latest report missing:\n%s", data)
+	}
+
+	c.MaxFiles = 10
+	c.MaxAge = time.Minute
+	old := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(files[0], old, old)
+	if _, err := c.Report("test", 5, nil); err != nil {
+		t.Fatal(err)
+	}
+	if files = c.Files(); len(files) != 3 {
+		t.Fatalf("files = %v", files)
+	}
+}
+
+func TestCrashGuard(t *testing.T) {
+	l := New(nil, nil)
+	l.Crash = NewCrashReporter(t.TempDir())
+
+	// start中的panic写入报告后继续抛出
+	func() {
+		defer func() {
+			if r := recover(); r != "start failed" {
+				t.Fatalf("recover = %v", r)
+			}
+		}()
+		defer l.guard("start")
+		panic("start failed")
+	}()
+	if len(l.Crash.Files()) != 1 {
+		t.Fatal("missing report for start")
+	}
+
+	// 工作协程的panic写入报告后重启
+	l.Supervisor.MinBackoff = time.Millisecond
+	l.Supervisor.OnPanic = func(name string, err *PanicError) {
+		l.reportCrash("worker:"+name, err.Value, err.Stack)
+	}
+	done := make(chan struct{})
+	l.Go("worker", func(ctx context.Context) error {
+		select {
+		case <-done:
+			<-ctx.Done()
+			return nil
+		default:
+			close(done)
+			panic("worker failed")
+		}
+	})
+	waitFor(t, func() bool { return len(l.Crash.Files()) == 2 })
+	l.Supervisor.Stop()
+	data, _ := os.ReadFile(l.Crash.Files()[1])
+	if !strings.Contains(string(data), "Source: worker:worker\n") {
+		t.Fatalf("report:\n%s", data)
+	}
+	// 模块启动时的panic写入报告后回滚
+	l.Modules.OnPanic = func(name string, err *PanicError) {
+		l.reportCrash("module:"+name, err.Value, err.Stack)
+	}
+	_ = l.Register(Module{Name: "db", Start: func(ctx context.Context) error {
+		panic("module failed")
+	}})
+	if err := l.Modules.Start(context.Background()); err == nil || err.Error() != "module db: panic: module failed" {
+		t.Fatalf("err = %v", err)
+	}
+	if files := l.Crash.Files(); len(files) != 3 {
+		t.Fatalf("files = %v", files)
+	}
+	data, _ = os.ReadFile(l.Crash.Files()[2])
+	if !strings.Contains(string(data), "Source: module:db\n") || !strings.Contains(string(data), "module failed") {
+		t.Fatalf("report:\n%s", data)
+	}
+}
@@ -0,0 +1,226 @@
+package qlauncher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCheckTimeout 默认的单项检查超时时间
+const DefaultCheckTimeout = time.Second * 5
+
+// CheckResult 单项检查结果
+type CheckResult struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// WorkerReport 工作协程状态，用于/status输出
+type WorkerReport struct {
+	Name      string        `json:"name"`
+	Policy    RestartPolicy `json:"policy"`
+	Running   bool          `json:"running"`
+	Restarts  int           `json:"restarts"`
+	LastError string        `json:"lastError,omitempty"`
+}
+
+// HealthStatus /status 返回的服务状态
+type HealthStatus struct {
+	Name      string         `json:"name"`
+	Version   string         `json:"version"`
+	PID       int            `json:"pid"`
+	StartedAt time.Time      `json:"startedAt"`
+	Uptime    string         `json:"uptime"`
+	Ready     bool           `json:"ready"`
+	Checks    []CheckResult  `json:"checks"`
+	Workers   []WorkerReport `json:"workers,omitempty"`
+}
+
+type healthCheck struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Health 服务健康状态
+//
+//	/healthz 存活检查，进程能响应即返回200；
+//	/readyz 就绪检查，启动完成、未在退出且所有检查通过时返回200，否则返回503；
+//	/status 返回服务信息及各项检查结果的JSON
+type Health struct {
+	Name         string                // 服务名
+	Version      string                // 版本号
+	CheckTimeout time.Duration         // 单项检查超时时间，0使用DefaultCheckTimeout
+	Workers      func() []WorkerStatus // 获取工作协程状态，可为nil
+
+	mu        sync.RWMutex
+	checks    []healthCheck
+	ready     atomic.Bool
+	startedAt time.Time
+}
+
+// NewHealth 创建健康状态
+//
+//	@return *Health
+func NewHealth() *Health {
+	return &Health{startedAt: time.Now()}
+}
+
+// RegisterCheck 注册就绪检查项，同名时替换
+//
+//	@param name 名称
+//	@param fn 检查方法，返回nil表示通过，ctx在CheckTimeout后取消
+func (h *Health) RegisterCheck(name string, fn func(ctx context.Context) error) {
+	if fn == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, c := range h.checks {
+		if c.name == name {
+			h.checks[i].fn = fn
+			return
+		}
+	}
+	h.checks = append(h.checks, healthCheck{name: name, fn: fn})
+}
+
+// SetReady 设置启动状态，启动完成后为true，开始退出时为false
+//
+//	@param ready
+func (h *Health) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// Check 并行执行所有检查项
+//
+//	@param ctx
+//	@return []CheckResult 按注册顺序排列
+//	@return bool 是否全部通过
+func (h *Health) Check(ctx context.Context) ([]CheckResult, bool) {
+	h.mu.RLock()
+	checks := append([]healthCheck(nil), h.checks...)
+	h.mu.RUnlock()
+
+	timeout := h.CheckTimeout
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c healthCheck) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c, timeout)
+		}(i, c)
+	}
+	wg.Wait()
+
+	ok := true
+	for _, r := range results {
+		ok = ok && r.OK
+	}
+	return results, ok
+}
+
+// runCheck 执行单项检查，超时或panic视为未通过
+func runCheck(ctx context.Context, c healthCheck, timeout time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	begin := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := CheckResult{Name: c.name, OK: err == nil, Duration: time.Since(begin).String()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Status 获取服务状态
+//
+//	@param ctx
+//	@return HealthStatus
+func (h *Health) Status(ctx context.Context) HealthStatus {
+	checks, ok := h.Check(ctx)
+	st := HealthStatus{
+		Name:      h.Name,
+		Version:   h.Version,
+		PID:       os.Getpid(),
+		StartedAt: h.startedAt,
+		Uptime:    time.Since(h.startedAt).Round(time.Second).String(),
+		Ready:     h.ready.Load() && ok,
+		Checks:    checks,
+	}
+	if h.Workers != nil {
+		for _, w := range h.Workers() {
+			r := WorkerReport{Name: w.Name, Policy: w.Policy, Running: w.Running, Restarts: w.Restarts}
+			if w.LastError != nil {
+				r.LastError = w.LastError.Error()
+			}
+			st.Workers = append(st.Workers, r)
+		}
+	}
+	return st
+}
+
+// Handler 获取健康检查的HTTP处理器，可挂载到已有的Web服务中
+//
+//	@return http.Handler
+func (h *Health) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !h.ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("not ready"))
+			return
+		}
+		results, ok := h.Check(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			for _, c := range results {
+				if !c.OK {
+					_, _ = fmt.Fprintf(w, "%s: %s\n", c.Name, c.Error)
+				}
+			}
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		st := h.Status(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if !st.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(st)
+	})
+	return mux
+}
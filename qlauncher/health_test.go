@@ -0,0 +1,143 @@
+package qlauncher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestHealthHandler(t *testing.T) {
+	h := NewHealth()
+	h.Name = "demo"
+	h.Version = "1.2.0"
+	h.CheckTimeout = time.Millisecond * 50
+	var dbErr error
+	h.RegisterCheck("db", func(ctx context.Context) error { return dbErr })
+	h.RegisterCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	srv := httptest.NewServer(h.Handler())
+	defer srv.Close()
+
+	if code, _ := get(t, srv.URL+"/healthz"); code != http.StatusOK {
+		t.Fatalf("healthz = %d", code)
+	}
+	// 启动完成前未就绪
+	if code, _ := get(t, srv.URL+"/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz before start = %d", code)
+	}
+
+	h.SetReady(true)
+	// slow超时
+	code, body := get(t, srv.URL+"/readyz")
+	if code != http.StatusServiceUnavailable || !strings.Contains(body, "slow: context deadline exceeded") {
+		t.Fatalf("readyz = %d %s", code, body)
+	}
+
+	h.RegisterCheck("slow", func(ctx context.Context) error { return nil })
+	if code, body = get(t, srv.URL+"/readyz"); code != http.StatusOK {
+		t.Fatalf("readyz = %d %s", code, body)
+	}
+
+	dbErr = errors.New("connection refused")
+	code, body = get(t, srv.URL+"/status")
+	var st HealthStatus
+	if err := json.Unmarshal([]byte(body), &st); err != nil {
+		t.Fatal(err)
+	}
+	if code != http.StatusServiceUnavailable || st.Ready || st.Name != "demo" || st.Version != "1.2.0" || len(st.Checks) != 2 {
+		t.Fatalf("status = %d %+v", code, st)
+	}
+	if st.Checks[0].Name != "db" || st.Checks[0].OK || st.Checks[0].Error != "connection refused" || !st.Checks[1].OK {
+		t.Fatalf("checks = %+v", st.Checks)
+	}
+}
+
+func TestSdNotify(t *testing.T) {
+	if ok, err := SdNotify("READY=1"); ok || err != nil {
+		t.Fatalf("without socket = %v %v", ok, err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	if ok, err := SdNotify("READY=1"); !ok || err != nil {
+		t.Fatalf("notify = %v %v", ok, err)
+	}
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "READY=1" {
+		t.Fatalf("read = %q %v", buf[:n], err)
+	}
+
+	t.Setenv("WATCHDOG_USEC", "3000000")
+	t.Setenv("WATCHDOG_PID", "")
+	if d := sdWatchdogInterval(); d != time.Second*3 {
+		t.Fatalf("watchdog = %v", d)
+	}
+	t.Setenv("WATCHDOG_PID", "1")
+	if d := sdWatchdogInterval(); d != 0 {
+		t.Fatalf("watchdog for other pid = %v", d)
+	}
+}
+
+func TestWatchdogSkipsFailedCheck(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	var healthy atomic.Bool
+	l := New(nil, nil)
+	l.RegisterCheck("loop", func(ctx context.Context) error {
+		if !healthy.Load() {
+			return errors.New("stuck")
+		}
+		return nil
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go l.watchdog(time.Millisecond*20, done)
+
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Millisecond * 200))
+	if n, err := conn.Read(buf); err == nil {
+		t.Fatalf("unexpected %q while unhealthy", buf[:n])
+	}
+	healthy.Store(true)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "WATCHDOG=1" {
+		t.Fatalf("read = %q %v", buf[:n], err)
+	}
+}
@@ -1,23 +1,30 @@
 package qlauncher
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/kamioair/utils/qio"
-	"github.com/kamioair/utils/qmonitor"
 	"github.com/kardianos/service"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 //func main() {
 //	// 启动
-//	qlauncher.Run(start, stop)
+//	qlauncher.Run(start, stop, false)
 //}
 //
 //func start() {
@@ -29,142 +36,721 @@ import (
 //	// 进行自己项目的相关释放
 //	// 如停止web服务
 //}
+//
+// 需要感知退出信号时，使用 Launcher：
+//
+//func main() {
+//	l := qlauncher.New(func(ctx context.Context) {
+//		go worker(ctx) // ctx在收到退出信号或调用Exit时取消
+//	}, stop)
+//	l.StopTimeout = time.Second * 10
+//	_ = l.Run()
+//}
+//
+// 后台循环使用 Go 启动，panic或出错时自动重启，退出时按启动的逆序停止：
+//
+//func start(ctx context.Context) {
+//	qlauncher.Go("consumer", func(ctx context.Context) error {
+//		return consume(ctx)
+//	})
+//}
+//
+// 一个程序包含多个模块时，按依赖关系注册，启动失败时已启动的模块自动回滚：
+//
+//func main() {
+//	l := qlauncher.New(nil, nil)
+//	_ = l.Register(qlauncher.Module{Name: "db", Start: db.Start, Stop: db.Stop})
+//	_ = l.Register(qlauncher.Module{Name: "api", DependsOn: []string{"db"}, Start: api.Start, Stop: api.Stop})
+//	_ = l.Run()
+//}
+
+// DefaultStopTimeout 默认的优雅退出等待时间
+const DefaultStopTimeout = time.Second * 30
 
 var (
-	stopChan = make(chan struct{}, 1)
-	wg       = &sync.WaitGroup{}
-	exitWg   = &sync.WaitGroup{}
-	serv     service.Service
+	stdMu sync.Mutex
+	std   *Launcher
+
+	// defaultBackend New创建的启动器使用的系统服务后端
+	defaultBackend Backend = SystemBackend{}
 )
 
 // Run 运行服务
+//
+//	singleton 为true时通过锁文件保证只运行一个实例，已有实例运行时记录日志后返回；
+//	与旧版本一致，退出时不限制stop的执行时间
 func Run(start func(), stop func(), singleton bool) {
-	// 单例运行
-	if singleton {
-		// 获取可执行文件的路径
-		execPath, err := os.Executable()
-		if err != nil {
-			fmt.Printf("获取进程路径失败: %v\n", err)
-			return
+	l := New(func(ctx context.Context) {
+		if start != nil {
+			start()
 		}
-		// 判断进程是否启动
-		processName := qio.GetFileName(execPath)
-		if qmonitor.GetProcessCount(processName) > 1 {
+	}, stop)
+	l.Singleton = singleton
+	l.StopTimeout = 0
+
+	stdMu.Lock()
+	std = l
+	stdMu.Unlock()
+
+	if err := l.Run(); err != nil {
+		if errors.Is(err, ErrLocked) {
 			return
 		}
+		log.Fatalln(err)
+	}
+}
+
+// Go 在当前服务中启动受监管的工作协程，返回错误或panic时重启
+//
+//	@param name 名称，用于日志
+//	@param fn 工作方法，ctx取消后应尽快返回
+func Go(name string, fn func(ctx context.Context) error) {
+	stdMu.Lock()
+	l := std
+	stdMu.Unlock()
+	if l == nil {
+		log.Println(fmt.Sprintf("[%s] Launcher not running, worker not started", name))
+		return
 	}
-	setup(start, nil, nil, stop)
+	l.Go(name, fn)
 }
 
-// Exit 退出服务
+// RegisterCheck 在当前服务中注册就绪检查项
+//
+//	@param name 名称
+//	@param fn 检查方法，返回nil表示通过
+func RegisterCheck(name string, fn func(ctx context.Context) error) {
+	stdMu.Lock()
+	l := std
+	stdMu.Unlock()
+	if l == nil {
+		log.Println(fmt.Sprintf("[%s] Launcher not running, check not registered", name))
+		return
+	}
+	l.RegisterCheck(name, fn)
+}
+
+// Exit 退出服务，服务已启动时阻塞直到stop执行完成，不能在stop中调用
 func Exit() {
-	time.Sleep(time.Millisecond * 100)
-	close(stopChan)
-	wg.Wait()
+	stdMu.Lock()
+	l := std
+	stdMu.Unlock()
+	if l == nil {
+		return
+	}
+	l.Exit()
+	if l.started.Load() {
+		<-l.stopped
+	}
 }
 
-type program struct {
-	start      func()
-	startEx    func(param interface{})
-	startParam interface{}
-	stop       func()
+// Launcher 服务启动器
+//
+//	start 收到的ctx在收到SIGINT/SIGTERM或调用Exit时取消，随后按逆序停止工作协程并执行stop；
+//	两者合计超过StopTimeout仍未完成时记录日志并强制结束进程
+type Launcher struct {
+	StopTimeout time.Duration  // 优雅退出的最长等待时间，0不限制
+	Options     Options        // 服务标识与运行选项
+	Supervisor  *Supervisor    // 工作协程监管器
+	Health      *Health        // 健康状态，Options.HealthAddr不为空时通过HTTP提供
+	Modules     *Modules       // 模块集合，在start之前按依赖顺序启动，在stop之后按相反顺序停止
+	Crash       *CrashReporter // 崩溃报告，为nil且Options.CrashDir不为空时自动创建
+	Backend     Backend        // 系统服务后端，默认为SystemBackend，需在Run之前设置
+
+	// Singleton 为true时通过锁文件(Options.LockFile)保证只运行一个实例，已有实例运行时Run返回*LockedError
+	Singleton bool
+	// NotifySignal 单例模式下已有实例运行时向其发送的信号，如 syscall.SIGHUP，nil不发送；Windows不支持
+	NotifySignal os.Signal
+	// OnNotify 本实例收到NotifySignal时的回调，如切换到前台或重新加载配置
+	OnNotify func(sig os.Signal)
+	// UpgradeTimeout 平滑重启时等待新进程就绪的时间，0使用DefaultUpgradeTimeout
+	UpgradeTimeout time.Duration
+
+	args      []string // 命令行参数，不含程序名
+	start     func(ctx context.Context)
+	stop      func()
+	ctx       context.Context
+	cancel    context.CancelFunc
+	serv      service.Service
+	stopOnce  sync.Once
+	stopped   chan struct{}                              // stop执行完成后关闭
+	startDone chan struct{}                              // 启动流程结束后关闭，无论成功与否
+	exit      func(code int)                             // 强制结束进程的方法
+	notify    func(c chan<- os.Signal, sig ...os.Signal) // 注册退出信号的方法
+
+	listenMu    sync.Mutex
+	listeners   map[string]net.Listener // 通过Listen创建的监听器
+	listenOrder []string
+	pidLock     *PIDLock    // 单例锁，未启用时为nil
+	upgrading   atomic.Bool // 正在平滑重启
+	started     atomic.Bool // start已执行完成
+	handedOver  atomic.Bool // 监听器和单例锁已交给新进程
 }
 
-func setup(start func(), startEx func(param interface{}), param interface{}, stop func()) {
-	// 初始化系统服务
-	pm := &program{
-		start:      start,
-		startEx:    startEx,
-		startParam: param,
-		stop:       stop,
+// New 创建服务启动器
+//
+//	@param start 启动方法，ctx在服务退出时取消
+//	@param stop 停止方法
+//	@return *Launcher
+func New(start func(ctx context.Context), stop func()) *Launcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	sup := NewSupervisor()
+	health := NewHealth()
+	health.Workers = sup.Workers
+	return &Launcher{
+		StopTimeout: DefaultStopTimeout,
+		Supervisor:  sup,
+		Health:      health,
+		Modules:     NewModules(),
+		args:        os.Args[1:],
+		start:       start,
+		stop:        stop,
+		ctx:         ctx,
+		cancel:      cancel,
+		stopped:     make(chan struct{}),
+		startDone:   make(chan struct{}),
+		Backend:     defaultBackend,
+		exit:        os.Exit,
+		notify:      signal.Notify,
 	}
-	// 获取当前程序所在路径
-	cd, err := qio.GetCurrentFilePath()
-	cd = strings.Replace(cd, "\\", "/", -1)
-	n1 := strings.Split(path.Dir(cd), "/")
-	n2 := strings.TrimSuffix(path.Base(cd), path.Ext(cd))
-	serv, err = service.New(pm, &service.Config{
-		// 统一使用 目录名_文件名 作为服务名
-		Name: n1[len(n1)-1] + "_" + n2,
-	})
-	if err != nil {
-		log.Fatalln(err)
-		return
+}
+
+// Run 运行服务，阻塞直到服务退出且stop执行完成
+//
+//	命令行第一个参数为 install、uninstall、start、stop、restart、status 时，执行对应的服务管理命令后返回
+//	@return error
+func (l *Launcher) Run() error {
+	if err := l.setup(); err != nil {
+		return err
 	}
-	// 修改当前工作目录为exe所在目录
-	// 如果不执行该操作，注册生成服务后，程序路径会默认在系统盘
-	dir, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-	err = os.Chdir(dir)
-	if err != nil {
-		log.Fatalln(err)
-		return
+	if len(l.args) > 0 && isCommand(l.args[0]) {
+		return l.Control(l.args[0])
+	}
+
+	if l.Crash != nil {
+		defer l.Crash.CaptureLog()()
+		l.Supervisor.OnPanic = func(name string, err *PanicError) {
+			l.reportCrash("worker:"+name, err.Value, err.Stack)
+		}
+		l.Modules.OnPanic = func(name string, err *PanicError) {
+			l.reportCrash("module:"+name, err.Value, err.Stack)
+		}
+	}
+
+	if l.NotifySignal != nil {
+		// 需在加锁前注册，避免后启动的实例发送信号时按默认行为结束本进程
+		notify := make(chan os.Signal, 1)
+		signal.Notify(notify, l.NotifySignal)
+		defer signal.Stop(notify)
+		go l.watchNotify(notify)
+	}
+	if l.Singleton {
+		lock, err := l.lock()
+		if err != nil {
+			return err
+		}
+		l.pidLock = lock
+		defer func() {
+			if l.handedOver.Load() {
+				lock.detach()
+			} else {
+				_ = lock.Release()
+			}
+		}()
+	}
+	if l.Options.HotRestart {
+		if sigs := upgradeSignals(); len(sigs) > 0 {
+			upgrade := make(chan os.Signal, 1)
+			signal.Notify(upgrade, sigs...)
+			defer signal.Stop(upgrade)
+			go l.watchUpgrade(upgrade)
+		}
+	}
+	if l.Options.HealthAddr != "" {
+		stopHealth, err := l.serveHealth(l.Options.HealthAddr)
+		if err != nil {
+			return err
+		}
+		defer stopHealth()
+	}
+	if interval := sdWatchdogInterval(); interval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go l.watchdog(interval/2, done)
 	}
 
 	// 如果是linux系统且未安装改服务时
 	if runtime.GOOS == "linux" {
-		st, se := serv.Status()
-		if st == service.StatusUnknown && se.Error() == "the service is not installed" {
+		st, se := l.serv.Status()
+		if st == service.StatusUnknown && se != nil && se.Error() == "the service is not installed" {
 			// 如果有对应的服务部署文件
-			if qio.PathExists(fmt.Sprintf("/lib/systemd/system/%s.service", serv.String())) {
-				err = serv.Install()
+			if qio.PathExists(fmt.Sprintf("/lib/systemd/system/%s.service", l.serv.String())) {
+				err := l.serv.Install()
 				if err != nil {
-					log.Println(fmt.Sprintf("[%s] Installed Error, %s", serv.String(), err))
+					log.Println(fmt.Sprintf("[%s] Installed Error, %s", l.serv.String(), err))
 				} else {
-					log.Println(fmt.Sprintf("[%s] Installed OK", serv.String()))
+					log.Println(fmt.Sprintf("[%s] Installed OK", l.serv.String()))
 				}
 			}
 		}
 	}
+
+	// 处理退出信号
+	signals := make(chan os.Signal, 2)
+	l.notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+	go l.watchSignals(signals)
+
 	// 运行
-	exitWg.Add(1)
+	errs := make(chan error, 1)
 	go func() {
-		err = serv.Run()
+		errs <- l.serv.Run()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-l.ctx.Done():
+	}
+	// 等待启动流程结束，启动失败时serv.Run返回对应的错误
+	select {
+	case err := <-errs:
+		return err
+	case <-l.startDone:
+	}
+	if !l.started.Load() {
+		return <-errs
+	}
+	// 调用Exit或收到信号时由启动器自己执行停止，Windows服务模式下不会等到服务管理器调用Stop
+	l.shutdown()
+	return nil
+}
+
+// Control 执行服务管理命令
+//
+//	@param action install、uninstall、start、stop、restart、status
+//	@return error
+func (l *Launcher) Control(action string) error {
+	if l.serv == nil {
+		if err := l.setup(); err != nil {
+			return err
+		}
+	}
+	if action == "status" {
+		st, err := l.serv.Status()
 		if err != nil {
-			panic(err)
+			fmt.Println(fmt.Sprintf("[%s] Status: %s", l.serv.String(), err))
+			return err
 		}
-	}()
-	exitWg.Wait()
+		fmt.Println(fmt.Sprintf("[%s] Status: %s", l.serv.String(), statusText(st)))
+		return nil
+	}
+	if err := service.Control(l.serv, action); err != nil {
+		return err
+	}
+	fmt.Println(fmt.Sprintf("[%s] %s OK", l.serv.String(), strings.ToUpper(action[:1])+action[1:]))
+	return nil
 }
 
-func (p *program) Start(s service.Service) error {
-	// 执行外层启动
-	if p.start != nil {
-		p.start()
+// setup 创建系统服务
+func (l *Launcher) setup() error {
+	// 获取当前程序所在路径
+	cd, err := qio.GetCurrentFilePath()
+	if err != nil {
+		return err
+	}
+	exec := cd
+	cd = strings.Replace(cd, "\\", "/", -1)
+	n1 := strings.Split(path.Dir(cd), "/")
+	n2 := strings.TrimSuffix(path.Base(cd), path.Ext(cd))
+	opts := l.Options
+	if opts.Name == "" {
+		// 统一使用 目录名_文件名 作为服务名
+		opts.Name = n1[len(n1)-1] + "_" + n2
 	}
-	if p.startEx != nil {
-		p.startEx(p.startParam)
+	if opts.WorkingDirectory == "" {
+		// 修改当前工作目录为exe所在目录
+		// 如果不执行该操作，注册生成服务后，程序路径会默认在系统盘
+		opts.WorkingDirectory, _ = filepath.Abs(filepath.Dir(os.Args[0]))
+	}
+	err = os.Chdir(opts.WorkingDirectory)
+	if err != nil {
+		return err
 	}
 
-	// 启动成功
-	fmt.Println(fmt.Sprintf("[%s] Started OK", s.String()))
+	unit := Unit{
+		Name:             opts.Name,
+		Description:      opts.Description,
+		ExecStart:        exec,
+		Arguments:        opts.Arguments,
+		WorkingDirectory: opts.WorkingDirectory,
+		User:             opts.UserName,
+		Dependencies:     opts.Dependencies,
+		Restart:          opts.Restart,
+		RestartSec:       opts.RestartSec,
+		WatchdogSec:      opts.WatchdogSec,
+		Env:              opts.Env,
+	}
+	if opts.HotRestart {
+		// 新进程通过MAINPID成为主进程
+		unit.NotifyAccess = "all"
+	}
+	l.Health.Name = opts.Name
+	if l.Crash == nil && opts.CrashDir != "" {
+		l.Crash = NewCrashReporter(opts.CrashDir)
+		l.Crash.MaxFiles = opts.CrashMaxFiles
+		l.Crash.Snapshot = func() any {
+			return opts
+		}
+	}
+	if l.StopTimeout > 0 {
+		// 留出强制退出和打印日志的时间
+		unit.TimeoutStopSec = int(l.StopTimeout/time.Second) + 5
+	}
+
+	option := service.KeyValue{
+		// 由启动器自己处理退出信号
+		"RunWait": func() {
+			<-l.ctx.Done()
+		},
+	}
+	if opts.Restart != "" {
+		option["Restart"] = opts.Restart
+	}
+	if l.Backend.Platform() == "linux-systemd" {
+		// 使用自己生成的单元文件，kardianos/service会按模板解析，需转义模板标记
+		option["SystemdScript"] = strings.ReplaceAll(unit.Render(), "{{", `{{"{{"}}`)
+	}
+	l.serv, err = l.Backend.New(&program{l: l}, &service.Config{
+		Name:             opts.Name,
+		DisplayName:      opts.DisplayName,
+		Description:      opts.Description,
+		UserName:         opts.UserName,
+		Arguments:        opts.Arguments,
+		Executable:       exec,
+		WorkingDirectory: opts.WorkingDirectory,
+		Dependencies:     opts.Dependencies,
+		EnvVars:          opts.Env,
+		Option:           option,
+	})
+	return err
+}
 
-	wg.Add(1)
-	stopChan = make(chan struct{})
-	go p.run()
+// isCommand 判断是否为服务管理命令
+func isCommand(arg string) bool {
+	switch arg {
+	case "install", "uninstall", "start", "stop", "restart", "status":
+		return true
+	}
+	return false
+}
 
-	return nil
+func statusText(st service.Status) string {
+	switch st {
+	case service.StatusRunning:
+		return "running"
+	case service.StatusStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+// Exit 通知服务退出，可重复调用，Run在stop执行完成后返回
+//
+//	不依赖系统服务管理器调用Stop，Windows服务模式下同样生效
+func (l *Launcher) Exit() {
+	l.cancel()
+}
+
+// Register 注册模块，需在Run之前调用
+//
+//	@param m
+//	@return error
+func (l *Launcher) Register(m Module) error {
+	return l.Modules.Register(m)
+}
+
+// RegisterCheck 注册就绪检查项，结果通过/readyz和/status提供
+//
+//	@param name 名称
+//	@param fn 检查方法，返回nil表示通过
+func (l *Launcher) RegisterCheck(name string, fn func(ctx context.Context) error) {
+	l.Health.RegisterCheck(name, fn)
+}
+
+// serveHealth 启动健康检查HTTP服务
+func (l *Launcher) serveHealth(addr string) (func(), error) {
+	ln, err := l.Listen("health", "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: l.Health.Handler(), ReadHeaderTimeout: time.Second * 5}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Println(fmt.Sprintf("[%s] Health server error, %s", l.name(), err))
+		}
+	}()
+	log.Println(fmt.Sprintf("[%s] Health server on %s", l.name(), ln.Addr()))
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}, nil
 }
 
-func (p *program) run() {
+// watchdog 定期执行就绪检查，全部通过时向systemd发送看门狗心跳，直到服务退出
+//
+//	检查未通过或超时(如服务死锁)时不发送，由systemd在WatchdogSec后重启服务
+func (l *Launcher) watchdog(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-stopChan:
-			// 执行外层停止
-			if p.stop != nil {
-				p.stop()
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			results, ok := l.Health.Check(ctx)
+			cancel()
+			if !ok {
+				for _, r := range results {
+					if !r.OK {
+						log.Println(fmt.Sprintf("[%s] Watchdog skipped, check %s failed, %s", l.name(), r.Name, r.Error))
+						break
+					}
+				}
+				continue
 			}
-			// 全部退出完成
-			wg.Done()
-			exitWg.Done()
+			_, _ = SdNotify("WATCHDOG=1")
+		case <-done:
 			return
 		}
 	}
 }
 
-func (p *program) Stop(s service.Service) error {
+// Go 启动受监管的工作协程，返回错误或panic时重启
+//
+//	@param name 名称，用于日志
+//	@param fn 工作方法，ctx在服务退出时按启动的逆序取消
+func (l *Launcher) Go(name string, fn func(ctx context.Context) error) {
+	l.Supervisor.Go(name, fn)
+}
+
+// GoWithPolicy 按指定的重启策略启动受监管的工作协程
+//
+//	@param name 名称，用于日志
+//	@param policy 重启策略
+//	@param fn 工作方法，ctx在服务退出时按启动的逆序取消
+func (l *Launcher) GoWithPolicy(name string, policy RestartPolicy, fn func(ctx context.Context) error) {
+	l.Supervisor.GoWithPolicy(name, policy, fn)
+}
+
+// Context 获取服务的上下文，服务退出时取消
+//
+//	@return context.Context
+func (l *Launcher) Context() context.Context {
+	return l.ctx
+}
+
+// lock 获取单例锁，已有实例运行时按NotifySignal通知该实例
+func (l *Launcher) lock() (*PIDLock, error) {
+	file := l.Options.LockFile
+	if file == "" {
+		file = filepath.Join(os.TempDir(), l.name()+".pid")
+	}
+	if f := inherit().takeLock(); f != nil {
+		// 平滑重启时接管旧进程的锁
+		lock := &PIDLock{path: file, file: f}
+		if err := lock.writePID(); err != nil {
+			lock.detach()
+			return nil, err
+		}
+		return lock, nil
+	}
+	lock, err := AcquireLock(file)
+	var locked *LockedError
+	if errors.As(err, &locked) {
+		log.Println(fmt.Sprintf("[%s] Already running, pid %d, lock %s", l.name(), locked.PID, file))
+		if l.NotifySignal != nil && locked.PID > 0 {
+			if e := signalProcess(locked.PID, l.NotifySignal); e != nil {
+				log.Println(fmt.Sprintf("[%s] Notify pid %d error, %s", l.name(), locked.PID, e))
+			} else {
+				log.Println(fmt.Sprintf("[%s] Notified pid %d with %v", l.name(), locked.PID, l.NotifySignal))
+			}
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pid := lock.StalePID(); pid > 0 {
+		log.Println(fmt.Sprintf("[%s] Replaced stale lock of pid %d", l.name(), pid))
+	}
+	return lock, nil
+}
+
+// watchUpgrade 收到平滑重启信号时启动新进程，新进程就绪后退出
+func (l *Launcher) watchUpgrade(upgrade chan os.Signal) {
+	for {
+		select {
+		case sig := <-upgrade:
+			log.Println(fmt.Sprintf("[%s] Received %v, upgrading", l.name(), sig))
+			if err := l.Upgrade(); err != nil {
+				log.Println(fmt.Sprintf("[%s] Upgrade error, %s", l.name(), err))
+				continue
+			}
+			l.Exit()
+			return
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
+
+// watchNotify 将收到的NotifySignal转交给OnNotify
+func (l *Launcher) watchNotify(notify chan os.Signal) {
+	for {
+		select {
+		case sig := <-notify:
+			if l.OnNotify != nil {
+				l.OnNotify(sig)
+			} else {
+				log.Println(fmt.Sprintf("[%s] Received %v, no handler", l.name(), sig))
+			}
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
+
+// reportCrash 写入崩溃报告
+func (l *Launcher) reportCrash(source string, value any, stack []byte) {
+	if l.Crash == nil {
+		return
+	}
+	file, err := l.Crash.Report(source, value, stack)
+	if err != nil {
+		log.Println(fmt.Sprintf("[%s] Crash report error, %s", l.name(), err))
+		return
+	}
+	log.Println(fmt.Sprintf("[%s] Crash report saved to %s", l.name(), file))
+}
+
+// guard 在start、stop中panic时写入崩溃报告后继续panic
+func (l *Launcher) guard(source string) {
+	if r := recover(); r != nil {
+		l.reportCrash(source, r, debug.Stack())
+		panic(r)
+	}
+}
+
+// watchSignals 第一次收到信号时优雅退出，再次收到时立即结束进程
+func (l *Launcher) watchSignals(signals chan os.Signal) {
+	sig, ok := <-signals
+	if !ok {
+		return
+	}
+	log.Println(fmt.Sprintf("[%s] Received %v, stopping", l.name(), sig))
+	l.cancel()
+
+	select {
+	case sig, ok = <-signals:
+		if ok {
+			log.Println(fmt.Sprintf("[%s] Received %v again, force exit", l.name(), sig))
+			l.exit(1)
+		}
+	case <-l.stopped:
+	}
+}
+
+func (l *Launcher) name() string {
+	if l.serv == nil {
+		return ""
+	}
+	return l.serv.String()
+}
+
+// shutdown 取消上下文并执行一次停止流程，重复调用时等待首次调用完成
+func (l *Launcher) shutdown() {
+	l.cancel()
+	l.Health.SetReady(false)
+	_, _ = SdNotify("STOPPING=1")
+	l.stopOnce.Do(func() {
+		// 执行外层停止
+		l.runStop()
+		close(l.stopped)
+		fmt.Println(fmt.Sprintf("[%s] Stoped OK", l.name()))
+	})
+}
+
+// runStop 停止工作协程，执行stop后停止模块，超过StopTimeout时强制结束进程
+func (l *Launcher) runStop() {
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		// 工作协程可能依赖stop中释放的资源，先停止
+		l.Supervisor.Stop()
+		if l.stop != nil {
+			func() {
+				defer l.guard("stop")
+				l.stop()
+			}()
+		}
+		l.Modules.Stop()
+	}()
+
+	if l.StopTimeout <= 0 {
+		<-finished
+		return
+	}
+	timer := time.NewTimer(l.StopTimeout)
+	defer timer.Stop()
+	select {
+	case <-finished:
+	case <-timer.C:
+		log.Println(fmt.Sprintf("[%s] Stop timeout after %v, force exit", l.name(), l.StopTimeout))
+		l.exit(1)
+	}
+}
+
+// program 对接 kardianos/service 的服务接口
+type program struct {
+	l *Launcher
+}
+
+func (p *program) Start(s service.Service) error {
+	defer close(p.l.startDone)
+
+	// 启动模块，失败时已启动的模块已回滚
+	if err := p.l.Modules.Start(p.l.ctx); err != nil {
+		p.l.cancel()
+		return err
+	}
+
+	// 执行外层启动
+	if p.l.start != nil {
+		func() {
+			defer p.l.guard("start")
+			p.l.start(p.l.ctx)
+		}()
+	}
+	p.l.started.Store(true)
+	p.l.Health.SetReady(true)
+	in := inherit()
+	if in.notifyReady() {
+		// 平滑重启的新进程，通知systemd主进程已变更
+		_, _ = SdNotify(fmt.Sprintf("MAINPID=%d", os.Getpid()))
+	}
+	in.closeUnclaimed()
+	_, _ = SdNotify("READY=1")
+
 	// 启动成功
-	fmt.Println(fmt.Sprintf("[%s] Stoped OK", s.String()))
-	close(stopChan)
-	wg.Wait()
+	fmt.Println(fmt.Sprintf("[%s] Started OK", s.String()))
+	return nil
+}
+
+func (p *program) Stop(s service.Service) error {
+	// 由系统服务管理器发起停止时，同样取消上下文
+	p.l.shutdown()
 	return nil
 }
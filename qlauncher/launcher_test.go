@@ -1,28 +1,386 @@
 package qlauncher
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/kardianos/service"
 )
 
-func TestRun(t *testing.T) {
+// fakeBackend 不接触系统服务管理器的服务后端
+type fakeBackend struct {
+	platform string
+	// scm 不为nil时模拟Windows服务模式：Start后不调用RunWait，直到关闭才由服务管理器调用Stop
+	scm chan struct{}
+
+	mu     sync.Mutex
+	calls  []string
+	config *service.Config
+}
+
+func (b *fakeBackend) New(i service.Interface, c *service.Config) (service.Service, error) {
+	b.mu.Lock()
+	b.config = c
+	b.mu.Unlock()
+	return &fakeService{b: b, i: i, c: c}, nil
+}
+
+func (b *fakeBackend) Platform() string {
+	return b.platform
+}
+
+func (b *fakeBackend) record(call string) error {
+	b.mu.Lock()
+	b.calls = append(b.calls, call)
+	b.mu.Unlock()
+	return nil
+}
+
+// fakeService 与kardianos/service交互模式的运行流程一致：Start、RunWait、Stop
+type fakeService struct {
+	b *fakeBackend
+	i service.Interface
+	c *service.Config
+}
+
+func (s *fakeService) Run() error {
+	if err := s.i.Start(s); err != nil {
+		return err
+	}
+	if s.b.scm != nil {
+		<-s.b.scm
+		return s.i.Stop(s)
+	}
+	if wait, ok := s.c.Option["RunWait"].(func()); ok {
+		wait()
+	}
+	return s.i.Stop(s)
+}
+
+func (s *fakeService) Start() error     { return s.b.record("start") }
+func (s *fakeService) Stop() error      { return s.b.record("stop") }
+func (s *fakeService) Restart() error   { return s.b.record("restart") }
+func (s *fakeService) Install() error   { return s.b.record("install") }
+func (s *fakeService) Uninstall() error { return s.b.record("uninstall") }
+func (s *fakeService) String() string   { return s.c.Name }
+func (s *fakeService) Platform() string { return s.b.platform }
+
+func (s *fakeService) Status() (service.Status, error) {
+	_ = s.b.record("status")
+	return service.StatusRunning, nil
+}
+
+func (s *fakeService) Logger(errs chan<- error) (service.Logger, error) {
+	return service.ConsoleLogger, nil
+}
+
+func (s *fakeService) SystemLogger(errs chan<- error) (service.Logger, error) {
+	return service.ConsoleLogger, nil
+}
+
+// events 记录执行顺序
+type events struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (e *events) add(event string) {
+	e.mu.Lock()
+	e.list = append(e.list, event)
+	e.mu.Unlock()
+}
+
+func (e *events) String() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return strings.Join(e.list, ",")
+}
+
+// testLauncher 在进程内运行的启动器，信号和强制退出均可由测试控制
+type testLauncher struct {
+	*Launcher
+	backend *fakeBackend
+	signals chan chan<- os.Signal
+	exits   chan int
+}
+
+func newTestLauncher(t *testing.T, start func(ctx context.Context), stop func()) *testLauncher {
+	wd, _ := os.Getwd()
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+
+	tl := &testLauncher{
+		Launcher: New(start, stop),
+		backend:  &fakeBackend{platform: "linux-systemd"},
+		signals:  make(chan chan<- os.Signal, 1),
+		exits:    make(chan int, 2),
+	}
+	tl.Backend = tl.backend
+	tl.Options.Name = "test_app"
+	tl.Options.WorkingDirectory = t.TempDir()
+	tl.args = nil
+	tl.notify = func(c chan<- os.Signal, sig ...os.Signal) {
+		tl.signals <- c
+	}
+	tl.exit = func(code int) {
+		tl.exits <- code
+	}
+	return tl
+}
+
+// run 在后台运行，返回Run的结果
+func (tl *testLauncher) run() chan error {
+	done := make(chan error, 1)
 	go func() {
-		time.Sleep(time.Second * 5)
-		Exit()
+		done <- tl.Run()
 	}()
-	Run(start, stop)
-	fmt.Println("finish")
+	return done
+}
+
+func wait[T any](t *testing.T, c <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(time.Second * 2):
+		t.Fatal("timeout")
+	}
+	var zero T
+	return zero
+}
+
+func TestLauncherStartStopOrder(t *testing.T) {
+	var ev events
+	started := make(chan struct{})
+	var tl *testLauncher
+	tl = newTestLauncher(t, func(ctx context.Context) {
+		ev.add("start")
+		tl.Go("worker", func(ctx context.Context) error {
+			<-ctx.Done()
+			ev.add("worker stopped")
+			return nil
+		})
+		close(started)
+	}, func() {
+		ev.add("stop")
+	})
+	_ = tl.Register(Module{
+		Name:  "db",
+		Start: func(ctx context.Context) error { ev.add("db start"); return nil },
+		Stop:  func() error { ev.add("db stop"); return nil },
+	})
+
+	done := tl.run()
+	wait(t, started)
+	waitFor(t, func() bool { return tl.Health.ready.Load() })
+	tl.Exit()
+	if err := wait(t, done); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ev.String(); got != "db start,start,worker stopped,stop,db stop" {
+		t.Fatalf("events = %s", got)
+	}
+	if tl.Health.ready.Load() {
+		t.Fatal("should not be ready after stop")
+	}
+	if tl.Context().Err() == nil {
+		t.Fatal("context should be cancelled")
+	}
+	script, _ := tl.backend.config.Option["SystemdScript"].(string)
+	if !strings.Contains(script, "ExecStart=") || tl.backend.config.Name != "test_app" {
+		t.Fatalf("config = %+v", tl.backend.config)
+	}
+}
+
+func TestLauncherExitInServiceMode(t *testing.T) {
+	var ev events
+	started := make(chan struct{})
+	tl := newTestLauncher(t, func(ctx context.Context) {
+		ev.add("start")
+		close(started)
+	}, func() {
+		ev.add("stop")
+	})
+	tl.backend.scm = make(chan struct{})
+	defer close(tl.backend.scm)
+
+	done := tl.run()
+	wait(t, started)
+	// 服务管理器不会调用Stop，Exit自己完成停止流程
+	tl.Exit()
+	if err := wait(t, done); err != nil {
+		t.Fatal(err)
+	}
+	if got := ev.String(); got != "start,stop" {
+		t.Fatalf("events = %s", got)
+	}
+}
+
+func TestLauncherSignals(t *testing.T) {
+	started := make(chan struct{})
+	stopping := make(chan struct{})
+	release := make(chan struct{})
+	stops := 0
+	tl := newTestLauncher(t, func(ctx context.Context) {
+		close(started)
+	}, func() {
+		stops++
+		close(stopping)
+		<-release
+	})
+
+	done := tl.run()
+	signals := wait(t, tl.signals)
+	wait(t, started)
+
+	// 第一次信号优雅退出
+	signals <- syscall.SIGTERM
+	wait(t, stopping)
+	if tl.Context().Err() == nil {
+		t.Fatal("context should be cancelled")
+	}
+	// stop未完成时再次收到信号立即退出
+	signals <- os.Interrupt
+	if code := wait(t, tl.exits); code != 1 {
+		t.Fatalf("exit code = %d", code)
+	}
+
+	close(release)
+	if err := wait(t, done); err != nil {
+		t.Fatal(err)
+	}
+	if stops != 1 {
+		t.Fatalf("stop called %d times", stops)
+	}
 }
 
-func start() {
-	fmt.Println("start1")
-	time.Sleep(time.Second)
-	fmt.Println("start2")
-	time.Sleep(time.Second)
+func TestLauncherExitTwice(t *testing.T) {
+	stops := 0
+	tl := newTestLauncher(t, func(ctx context.Context) {}, func() {
+		stops++
+	})
+	// Run之前调用Exit，启动后立即退出
+	tl.Exit()
+	tl.Exit()
+	if err := wait(t, tl.run()); err != nil {
+		t.Fatal(err)
+	}
+	tl.Exit()
+	if stops != 1 {
+		t.Fatalf("stop called %d times", stops)
+	}
+	select {
+	case code := <-tl.exits:
+		t.Fatalf("unexpected exit %d", code)
+	default:
+	}
 }
 
-func stop() {
-	fmt.Println("stop")
-	time.Sleep(time.Second)
+func TestLauncherStopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	tl := newTestLauncher(t, nil, func() {
+		<-release
+	})
+	tl.StopTimeout = time.Millisecond * 20
+	tl.Exit()
+
+	done := tl.run()
+	if code := wait(t, tl.exits); code != 1 {
+		t.Fatalf("exit code = %d", code)
+	}
+	if err := wait(t, done); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLauncherStartFailure(t *testing.T) {
+	stops := 0
+	tl := newTestLauncher(t, func(ctx context.Context) {
+		t.Error("start should not be called")
+	}, func() {
+		stops++
+	})
+	failed := errors.New("connect failed")
+	_ = tl.Register(Module{Name: "db", Start: func(ctx context.Context) error { return failed }})
+
+	if err := wait(t, tl.run()); !errors.Is(err, failed) {
+		t.Fatalf("err = %v", err)
+	}
+	if stops != 0 || tl.Context().Err() == nil {
+		t.Fatalf("stops = %d, ctx = %v", stops, tl.Context().Err())
+	}
+}
+
+func TestLauncherControl(t *testing.T) {
+	tl := newTestLauncher(t, func(ctx context.Context) {
+		t.Error("start should not be called")
+	}, nil)
+	tl.args = []string{"install"}
+	if err := wait(t, tl.run()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tl.Control("status"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tl.Control("restart"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tl.backend.calls, ","); got != "install,status,restart" {
+		t.Fatalf("calls = %s", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	backend := &fakeBackend{}
+	defaultBackend = backend
+	defer func() {
+		defaultBackend = SystemBackend{}
+	}()
+
+	var ev events
+	started := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		<-started
+		stdMu.Lock()
+		l := std
+		stdMu.Unlock()
+		for !l.started.Load() {
+			time.Sleep(time.Millisecond)
+		}
+		// 与旧版本一致，Exit阻塞到stop执行完成
+		Exit()
+		ev.add("exited")
+		Exit()
+	}()
+	Run(func() {
+		ev.add("start")
+		close(started)
+	}, func() {
+		time.Sleep(time.Millisecond * 50)
+		ev.add("stop")
+	}, false)
+
+	wait(t, exited)
+	stdMu.Lock()
+	timeout := std.StopTimeout
+	stdMu.Unlock()
+	if timeout != 0 {
+		t.Fatal("legacy Run should not limit stop")
+	}
+	if got := ev.String(); got != "start,stop,exited" {
+		t.Fatalf("events = %s", got)
+	}
 }
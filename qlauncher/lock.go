@@ -0,0 +1,149 @@
+package qlauncher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrLocked 锁文件已被其他进程持有
+var ErrLocked = errors.New("lock file is held by another process")
+
+// errLockHeld 平台实现加锁失败时返回
+var errLockHeld = errors.New("lock held")
+
+// LockedError 锁文件已被其他进程持有，可用 errors.Is(err, ErrLocked) 判断
+type LockedError struct {
+	Path string
+	PID  int // 持有锁的进程号，无法读取时为0
+}
+
+func (e *LockedError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("%s is locked by pid %d", e.Path, e.PID)
+	}
+	return fmt.Sprintf("%s is locked by another process", e.Path)
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// PIDLock 基于文件锁的进程锁，文件中记录持有者的进程号
+//
+//	锁随进程结束由系统释放，进程崩溃后残留的文件不会阻止下次启动
+type PIDLock struct {
+	path     string
+	file     *os.File
+	stalePID int
+}
+
+// AcquireLock 以独占方式获取锁文件，不阻塞
+//
+//	@param path 锁文件路径，目录不存在时自动创建
+//	@return *PIDLock
+//	@return error 已被其他进程持有时返回 *LockedError
+func AcquireLock(path string) (*PIDLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := openLocked(path)
+	if err != nil {
+		if errors.Is(err, errLockHeld) {
+			pid, _ := ReadLockPID(path)
+			return nil, &LockedError{Path: path, PID: pid}
+		}
+		return nil, err
+	}
+
+	l := &PIDLock{path: path, file: f}
+	// 文件中残留的进程号来自未正常释放的旧进程
+	if pid, err := readPID(f); err == nil && pid != os.Getpid() {
+		l.stalePID = pid
+	}
+	if err = l.writePID(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// ReadLockPID 读取锁文件中记录的进程号
+//
+//	@param path 锁文件路径
+//	@return int
+//	@return error
+func ReadLockPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// Path 锁文件路径
+//
+//	@return string
+func (l *PIDLock) Path() string {
+	return l.path
+}
+
+// StalePID 获取锁时文件中残留的旧进程号，没有残留时为0
+//
+//	@return int
+func (l *PIDLock) StalePID() int {
+	return l.stalePID
+}
+
+// Release 释放锁
+//
+//	只清空文件内容不删除文件，避免删除与其他进程加锁交错时出现两个持有者
+//	@return error
+func (l *PIDLock) Release() error {
+	if l.file == nil {
+		return nil
+	}
+	_ = l.file.Truncate(0)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// detach 关闭本进程的文件而不清空内容，锁已移交给平滑重启的新进程
+func (l *PIDLock) detach() {
+	if l.file != nil {
+		_ = l.file.Close()
+		l.file = nil
+	}
+}
+
+func (l *PIDLock) writePID() error {
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+func readPID(f *os.File) (int, error) {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 32))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// signalProcess 向进程发送信号，Windows仅支持os.Kill
+func signalProcess(pid int, sig os.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
@@ -0,0 +1,59 @@
+package qlauncher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAcquireLock(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "run", "app.pid")
+
+	lock, err := AcquireLock(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, _ := ReadLockPID(file); pid != os.Getpid() {
+		t.Fatalf("pid = %d", pid)
+	}
+
+	// 已持有时再次获取失败，并报告持有者
+	_, err = AcquireLock(file)
+	var locked *LockedError
+	if !errors.Is(err, ErrLocked) || !errors.As(err, &locked) || locked.PID != os.Getpid() {
+		t.Fatalf("err = %v", err)
+	}
+
+	if err = lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = AcquireLock(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.StalePID() != 0 {
+		t.Fatalf("stale pid = %d", lock.StalePID())
+	}
+	_ = lock.Release()
+}
+
+func TestAcquireLockStale(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.pid")
+	// 模拟崩溃进程残留的锁文件
+	if err := os.WriteFile(file, []byte("999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := AcquireLock(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	if lock.StalePID() != 999999 {
+		t.Fatalf("stale pid = %d", lock.StalePID())
+	}
+	if pid, _ := ReadLockPID(file); pid != os.Getpid() {
+		t.Fatalf("pid = %d", pid)
+	}
+}
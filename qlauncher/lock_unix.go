@@ -0,0 +1,24 @@
+//go:build !windows
+
+package qlauncher
+
+import (
+	"os"
+	"syscall"
+)
+
+// openLocked 打开并以flock独占锁定文件
+func openLocked(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errLockHeld
+		}
+		return nil, err
+	}
+	return f, nil
+}
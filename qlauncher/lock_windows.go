@@ -0,0 +1,32 @@
+//go:build windows
+
+package qlauncher
+
+import (
+	"os"
+	"syscall"
+)
+
+const errorSharingViolation syscall.Errno = 32
+
+// openLocked 以不允许其他进程写入的共享模式打开文件，句柄关闭时释放
+func openLocked(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(name,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		syscall.FILE_SHARE_READ, // 允许其他进程读取进程号
+		nil,
+		syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0)
+	if err != nil {
+		if err == errorSharingViolation {
+			return nil, errLockHeld
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
@@ -0,0 +1,247 @@
+package qlauncher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Module 同一程序中的一个逻辑模块
+type Module struct {
+	Name         string        // 模块名，唯一
+	DependsOn    []string      // 依赖的模块名，依赖的模块先启动、后停止
+	StartTimeout time.Duration // 启动超时时间，0不限制；超时后取消ctx，Start仍返回成功时调用Stop
+	// Start 启动方法，ctx在模块停止或启动失败时取消
+	Start func(ctx context.Context) error
+	// Stop 停止方法，只对启动成功的模块调用，可为nil
+	Stop func() error
+}
+
+// Modules 模块集合，按依赖关系的拓扑顺序启动，按相反顺序停止
+//
+//	某个模块启动失败或超时时，已启动的模块按相反顺序停止
+type Modules struct {
+	// OnPanic 模块Start中panic时的回调，在回滚之前调用，可为nil
+	OnPanic func(name string, err *PanicError)
+
+	mu      sync.Mutex
+	modules []Module
+	started []*runningModule // 已启动的模块，按启动顺序排列
+}
+
+type runningModule struct {
+	Module
+	cancel context.CancelFunc
+}
+
+// NewModules 创建模块集合
+//
+//	@return *Modules
+func NewModules() *Modules {
+	return &Modules{}
+}
+
+// Register 注册模块，需在Start之前调用
+//
+//	@param m
+//	@return error 模块名为空或重复时返回错误
+func (ms *Modules) Register(m Module) error {
+	if m.Name == "" {
+		return fmt.Errorf("module name is empty")
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, exist := range ms.modules {
+		if exist.Name == m.Name {
+			return fmt.Errorf("module %s already registered", m.Name)
+		}
+	}
+	ms.modules = append(ms.modules, m)
+	return nil
+}
+
+// Len 已注册的模块数量
+//
+//	@return int
+func (ms *Modules) Len() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return len(ms.modules)
+}
+
+// Order 按依赖关系计算启动顺序，无依赖关系的模块保持注册顺序
+//
+//	@return []string
+//	@return error 依赖不存在或存在循环依赖时返回错误
+func (ms *Modules) Order() ([]string, error) {
+	ms.mu.Lock()
+	modules := append([]Module(nil), ms.modules...)
+	ms.mu.Unlock()
+
+	sorted, err := sortModules(modules)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(sorted))
+	for i, m := range sorted {
+		names[i] = m.Name
+	}
+	return names, nil
+}
+
+// Start 按拓扑顺序依次启动模块，失败时停止已启动的模块并返回错误
+//
+//	@param ctx 模块的上下文均派生自该ctx
+//	@return error
+func (ms *Modules) Start(ctx context.Context) error {
+	ms.mu.Lock()
+	modules := append([]Module(nil), ms.modules...)
+	ms.mu.Unlock()
+
+	sorted, err := sortModules(modules)
+	if err != nil {
+		return err
+	}
+	for _, m := range sorted {
+		rm, err := startModule(ctx, m)
+		var pe *PanicError
+		if errors.As(err, &pe) {
+			if ms.OnPanic != nil {
+				ms.OnPanic(m.Name, pe)
+			}
+			// 调用栈已交给OnPanic，返回的错误只保留panic的值
+			err = fmt.Errorf("panic: %v", pe.Value)
+		}
+		if err != nil {
+			log.Println(fmt.Sprintf("[%s] Module start failed, %s, rollback", m.Name, err))
+			ms.Stop()
+			return fmt.Errorf("module %s: %w", m.Name, err)
+		}
+		ms.mu.Lock()
+		ms.started = append(ms.started, rm)
+		ms.mu.Unlock()
+		log.Println(fmt.Sprintf("[%s] Module started", m.Name))
+	}
+	return nil
+}
+
+// Stop 按启动的相反顺序停止已启动的模块，可重复调用
+func (ms *Modules) Stop() {
+	ms.mu.Lock()
+	started := ms.started
+	ms.started = nil
+	ms.mu.Unlock()
+
+	for i := len(started) - 1; i >= 0; i-- {
+		m := started[i]
+		if m.Stop != nil {
+			if err := m.Stop(); err != nil {
+				log.Println(fmt.Sprintf("[%s] Module stop error, %s", m.Name, err))
+			}
+		}
+		m.cancel()
+		log.Println(fmt.Sprintf("[%s] Module stopped", m.Name))
+	}
+}
+
+// startModule 启动单个模块，超时或panic视为失败，panic时返回*PanicError
+func startModule(parent context.Context, m Module) (*runningModule, error) {
+	ctx, cancel := context.WithCancel(parent)
+	if m.Start == nil {
+		return &runningModule{Module: m, cancel: cancel}, nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+		done <- m.Start(ctx)
+	}()
+
+	var timeout <-chan time.Time
+	if m.StartTimeout > 0 {
+		timer := time.NewTimer(m.StartTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &runningModule{Module: m, cancel: cancel}, nil
+	case <-timeout:
+		cancel()
+		// Start应在ctx取消后尽快返回，超时后仍启动成功时补充调用Stop释放资源
+		go stopLate(m, done)
+		return nil, fmt.Errorf("start timeout after %v", m.StartTimeout)
+	}
+}
+
+// stopLate 等待超时的Start返回，启动成功时调用Stop
+func stopLate(m Module, done chan error) {
+	if err := <-done; err != nil || m.Stop == nil {
+		return
+	}
+	log.Println(fmt.Sprintf("[%s] Module started after timeout, stopping", m.Name))
+	if err := m.Stop(); err != nil {
+		log.Println(fmt.Sprintf("[%s] Module stop error, %s", m.Name, err))
+	}
+}
+
+// sortModules 按依赖关系拓扑排序
+func sortModules(modules []Module) ([]Module, error) {
+	index := make(map[string]int, len(modules))
+	for i, m := range modules {
+		index[m.Name] = i
+	}
+	for _, m := range modules {
+		for _, dep := range m.DependsOn {
+			if _, ok := index[dep]; !ok {
+				return nil, fmt.Errorf("module %s depends on unknown module %s", m.Name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(modules))
+	sorted := make([]Module, 0, len(modules))
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		m := modules[i]
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("module dependency cycle: %s", strings.Join(append(path, m.Name), " -> "))
+		}
+		state[i] = visiting
+		for _, dep := range m.DependsOn {
+			if err := visit(index[dep], append(path, m.Name)); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		sorted = append(sorted, m)
+		return nil
+	}
+	for i := range modules {
+		if err := visit(i, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
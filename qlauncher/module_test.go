@@ -0,0 +1,163 @@
+package qlauncher
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordModules 注册记录启动和停止顺序的模块
+func recordModules(t *testing.T, ms *Modules, events *[]string, defs map[string][]string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		name := name
+		err := ms.Register(Module{
+			Name:      name,
+			DependsOn: defs[name],
+			Start: func(ctx context.Context) error {
+				*events = append(*events, "start "+name)
+				return nil
+			},
+			Stop: func() error {
+				*events = append(*events, "stop "+name)
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestModulesOrder(t *testing.T) {
+	ms := NewModules()
+	var events []string
+	recordModules(t, ms, &events, map[string][]string{
+		"api":  {"db", "mq"},
+		"mq":   {"log"},
+		"db":   {"log"},
+		"cron": nil,
+	}, "api", "cron", "mq", "db", "log")
+
+	order, err := ms.Order()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(order, ","); got != "log,db,mq,api,cron" {
+		t.Fatalf("order = %s", got)
+	}
+
+	if err = ms.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ms.Stop()
+	ms.Stop()
+	want := "start log,start db,start mq,start api,start cron,stop cron,stop api,stop mq,stop db,stop log"
+	if got := strings.Join(events, ","); got != want {
+		t.Fatalf("events = %s", got)
+	}
+}
+
+func TestModulesInvalid(t *testing.T) {
+	ms := NewModules()
+	if err := ms.Register(Module{}); err == nil {
+		t.Fatal("empty name should fail")
+	}
+	_ = ms.Register(Module{Name: "a", DependsOn: []string{"b"}})
+	if err := ms.Register(Module{Name: "a"}); err == nil {
+		t.Fatal("duplicate name should fail")
+	}
+	if _, err := ms.Order(); err == nil || !strings.Contains(err.Error(), "unknown module b") {
+		t.Fatalf("err = %v", err)
+	}
+
+	_ = ms.Register(Module{Name: "b", DependsOn: []string{"c"}})
+	_ = ms.Register(Module{Name: "c", DependsOn: []string{"a"}})
+	if err := ms.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestModulesRollback(t *testing.T) {
+	ms := NewModules()
+	var events []string
+	recordModules(t, ms, &events, nil, "a", "b")
+	failed := errors.New("bind failed")
+	_ = ms.Register(Module{
+		Name:      "c",
+		DependsOn: []string{"b"},
+		Start:     func(ctx context.Context) error { return failed },
+		Stop: func() error {
+			events = append(events, "stop c")
+			return nil
+		},
+	})
+
+	err := ms.Start(context.Background())
+	if !errors.Is(err, failed) {
+		t.Fatalf("err = %v", err)
+	}
+	if got := strings.Join(events, ","); got != "start a,start b,stop b,stop a" {
+		t.Fatalf("events = %s", got)
+	}
+}
+
+func TestModulesStartTimeout(t *testing.T) {
+	ms := NewModules()
+	var events []string
+	recordModules(t, ms, &events, nil, "a")
+	cancelled := make(chan struct{})
+	_ = ms.Register(Module{
+		Name:         "slow",
+		StartTimeout: time.Millisecond * 20,
+		Start: func(ctx context.Context) error {
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		},
+	})
+
+	err := ms.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "module slow: start timeout") {
+		t.Fatalf("err = %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("ctx of timed out module not cancelled")
+	}
+	if got := strings.Join(events, ","); got != "start a,stop a" {
+		t.Fatalf("events = %s", got)
+	}
+}
+
+func TestModulesStartTimeoutLateSuccess(t *testing.T) {
+	ms := NewModules()
+	release := make(chan struct{})
+	stopped := make(chan struct{})
+	_ = ms.Register(Module{
+		Name:         "slow",
+		StartTimeout: time.Millisecond * 20,
+		Start: func(ctx context.Context) error {
+			// 不理会ctx，超时后才启动成功
+			<-release
+			return nil
+		},
+		Stop: func() error {
+			close(stopped)
+			return nil
+		},
+	})
+
+	if err := ms.Start(context.Background()); err == nil {
+		t.Fatal("expected start timeout")
+	}
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("late started module not stopped")
+	}
+}
@@ -0,0 +1,52 @@
+package qlauncher
+
+import (
+	"github.com/kamioair/utils/qconfig"
+	"strings"
+)
+
+// Options 服务标识与运行选项，同一程序可使用不同的Name部署多份
+type Options struct {
+	Name             string            `comment:"服务名，为空时使用 目录名_文件名"`
+	DisplayName      string            `comment:"显示名称，可为空"`
+	Description      string            `comment:"服务描述，可为空"`
+	Arguments        []string          `comment:"以服务运行时的启动参数"`
+	WorkingDirectory string            `comment:"工作目录，为空时使用程序所在目录"`
+	Env              map[string]string `comment:"环境变量，从配置文件读取时变量名统一转为大写"`
+	Dependencies     []string          `comment:"依赖的服务，如 network-online.target"`
+	UserName         string            `comment:"运行用户，为空时使用系统默认用户"`
+	Restart          string            `comment:"重启策略 always/on-failure/no，为空时使用always"`
+	RestartSec       int               `comment:"重启间隔秒数，0使用默认值5"`
+	LockFile         string            `comment:"单例运行的锁文件路径，为空时使用 临时目录/服务名.pid"`
+	HealthAddr       string            `comment:"健康检查HTTP监听地址，如 127.0.0.1:8081，为空不启用"`
+	WatchdogSec      int               `comment:"systemd看门狗超时秒数，0不启用"`
+	CrashDir         string            `comment:"崩溃报告目录，为空不启用"`
+	CrashMaxFiles    int               `comment:"最多保留的崩溃报告数量，0使用默认值20"`
+	HotRestart       bool              `comment:"收到SIGUSR2时平滑重启，监听器和单例锁交给新进程，Windows不支持"`
+}
+
+// LoadOptions 从配置文件的 Base 配置节中读取服务选项，对应 Base 下的 Service 节点
+//
+//	Base:
+//	  Service:
+//	    Name: "gateway_a"
+//	    Description: "网关A"
+//
+//	@param cfgFile 配置文件路径
+//	@return Options
+//	@return error
+func LoadOptions(cfgFile string) (Options, error) {
+	opts := Options{}
+	if err := qconfig.LoadConfig(cfgFile, "Base.Service", &opts); err != nil {
+		return opts, err
+	}
+	// viper读取时会将key转为小写，环境变量名统一恢复为大写
+	if len(opts.Env) > 0 {
+		env := make(map[string]string, len(opts.Env))
+		for k, v := range opts.Env {
+			env[strings.ToUpper(k)] = v
+		}
+		opts.Env = env
+	}
+	return opts, nil
+}
@@ -0,0 +1,49 @@
+package qlauncher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOptions(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := `############################### Base Config ###############################
+Base:
+  Module: "gateway"
+  Service:
+    Name: "gateway_b"
+    DisplayName: "Gateway B"
+    Arguments:
+      - "-mode"
+      - "b"
+    Env:
+      LOG_LEVEL: "debug"
+    Dependencies:
+      - "network-online.target"
+    UserName: "edge"
+    RestartSec: 3
+`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := LoadOptions(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "gateway_b" || opts.DisplayName != "Gateway B" || opts.UserName != "edge" || opts.RestartSec != 3 {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+	if len(opts.Arguments) != 2 || opts.Arguments[1] != "b" {
+		t.Fatalf("unexpected arguments %v", opts.Arguments)
+	}
+	if opts.Env["LOG_LEVEL"] != "debug" {
+		t.Fatalf("unexpected env %v", opts.Env)
+	}
+	if len(opts.Dependencies) != 1 {
+		t.Fatalf("unexpected dependencies %v", opts.Dependencies)
+	}
+}
@@ -0,0 +1,226 @@
+package qlauncher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// RestartPolicy 工作协程的重启策略
+type RestartPolicy string
+
+const (
+	RestartAlways    RestartPolicy = "always"     // 无论是否出错都重启
+	RestartOnFailure RestartPolicy = "on-failure" // 返回错误或panic时重启
+	RestartNever     RestartPolicy = "never"      // 不重启
+)
+
+const (
+	// DefaultMinBackoff 默认的首次重启间隔
+	DefaultMinBackoff = time.Second
+	// DefaultMaxBackoff 默认的最大重启间隔
+	DefaultMaxBackoff = time.Minute
+)
+
+// PanicError 工作协程panic时转换成的错误
+type PanicError struct {
+	Value any    // recover得到的值
+	Stack []byte // panic时的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.Value, e.Stack)
+}
+
+// WorkerStatus 工作协程的运行状态
+type WorkerStatus struct {
+	Name      string
+	Policy    RestartPolicy
+	Running   bool      // 是否正在执行
+	Restarts  int       // 已重启次数
+	LastError error     // 最近一次的错误，panic时为*PanicError
+	StartedAt time.Time // 最近一次启动时间
+}
+
+// Supervisor 工作协程监管器
+//
+//	工作协程panic时恢复并记录调用栈，按重启策略以指数退避间隔重启；
+//	Stop时按启动顺序的逆序逐个取消并等待退出
+type Supervisor struct {
+	MinBackoff time.Duration // 首次重启间隔，0使用DefaultMinBackoff
+	MaxBackoff time.Duration // 最大重启间隔，0使用DefaultMaxBackoff；单次运行超过该时长后间隔重新计算
+	// OnPanic 工作协程panic时的回调，在重启之前调用，可为nil
+	OnPanic func(name string, err *PanicError)
+
+	mu      sync.Mutex
+	workers []*worker
+	stopped bool
+}
+
+type worker struct {
+	name   string
+	policy RestartPolicy
+	fn     func(ctx context.Context) error
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	status WorkerStatus
+}
+
+// NewSupervisor 创建工作协程监管器
+//
+//	@return *Supervisor
+func NewSupervisor() *Supervisor {
+	return &Supervisor{}
+}
+
+// Go 启动工作协程，返回错误或panic时重启
+//
+//	@param name 名称，用于日志
+//	@param fn 工作方法，ctx取消后应尽快返回
+func (s *Supervisor) Go(name string, fn func(ctx context.Context) error) {
+	s.GoWithPolicy(name, RestartOnFailure, fn)
+}
+
+// GoWithPolicy 按指定的重启策略启动工作协程
+//
+//	@param name 名称，用于日志
+//	@param policy 重启策略
+//	@param fn 工作方法，ctx取消后应尽快返回
+func (s *Supervisor) GoWithPolicy(name string, policy RestartPolicy, fn func(ctx context.Context) error) {
+	if fn == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		log.Println(fmt.Sprintf("[%s] Supervisor stopped, worker not started", name))
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &worker{
+		name:   name,
+		policy: policy,
+		fn:     fn,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		status: WorkerStatus{Name: name, Policy: policy},
+	}
+	s.workers = append(s.workers, w)
+	go s.run(w)
+}
+
+// Workers 获取所有工作协程的状态，按启动顺序排列
+//
+//	@return []WorkerStatus
+func (s *Supervisor) Workers() []WorkerStatus {
+	s.mu.Lock()
+	workers := append([]*worker(nil), s.workers...)
+	s.mu.Unlock()
+
+	list := make([]WorkerStatus, 0, len(workers))
+	for _, w := range workers {
+		w.mu.Lock()
+		list = append(list, w.status)
+		w.mu.Unlock()
+	}
+	return list
+}
+
+// Stop 按启动顺序的逆序逐个停止工作协程，阻塞直到全部退出，之后不再接受新的工作协程
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	workers := s.workers
+	s.mu.Unlock()
+
+	for i := len(workers) - 1; i >= 0; i-- {
+		workers[i].cancel()
+		<-workers[i].done
+	}
+}
+
+// run 执行工作协程并按策略重启
+func (s *Supervisor) run(w *worker) {
+	defer close(w.done)
+
+	backoff := s.minBackoff()
+	for {
+		started := time.Now()
+		w.mu.Lock()
+		w.status.Running = true
+		w.status.StartedAt = started
+		w.mu.Unlock()
+
+		err := w.call()
+
+		w.mu.Lock()
+		w.status.Running = false
+		w.status.LastError = err
+		w.mu.Unlock()
+
+		if w.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Println(fmt.Sprintf("[%s] Worker failed, %s", w.name, err))
+			if pe, ok := err.(*PanicError); ok && s.OnPanic != nil {
+				s.OnPanic(w.name, pe)
+			}
+		}
+		if w.policy == RestartNever || (w.policy == RestartOnFailure && err == nil) {
+			return
+		}
+
+		// 运行足够长时间后视为已恢复，重新计算间隔
+		if time.Since(started) >= s.maxBackoff() {
+			backoff = s.minBackoff()
+		}
+		log.Println(fmt.Sprintf("[%s] Worker restart in %v", w.name, backoff))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-w.ctx.Done():
+			timer.Stop()
+			return
+		}
+		backoff *= 2
+		if backoff > s.maxBackoff() {
+			backoff = s.maxBackoff()
+		}
+
+		w.mu.Lock()
+		w.status.Restarts++
+		w.mu.Unlock()
+	}
+}
+
+// call 执行一次工作方法，panic转换为*PanicError
+func (w *worker) call() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return w.fn(w.ctx)
+}
+
+func (s *Supervisor) minBackoff() time.Duration {
+	if s.MinBackoff > 0 {
+		return s.MinBackoff
+	}
+	return DefaultMinBackoff
+}
+
+func (s *Supervisor) maxBackoff() time.Duration {
+	if s.MaxBackoff > 0 {
+		return s.MaxBackoff
+	}
+	return DefaultMaxBackoff
+}
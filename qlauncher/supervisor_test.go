@@ -0,0 +1,149 @@
+package qlauncher
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestSupervisor() *Supervisor {
+	s := NewSupervisor()
+	s.MinBackoff = time.Millisecond
+	s.MaxBackoff = time.Millisecond * 4
+	return s
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 2)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSupervisorPanicRestart(t *testing.T) {
+	s := newTestSupervisor()
+	var runs int32
+	s.Go("panic", func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) < 3 {
+			panic("boom")
+		}
+		<-ctx.Done()
+		return nil
+	})
+	waitFor(t, func() bool { return atomic.LoadInt32(&runs) == 3 })
+
+	st := s.Workers()[0]
+	if st.Restarts != 2 {
+		t.Fatalf("restarts = %d", st.Restarts)
+	}
+	var pe *PanicError
+	if !errors.As(st.LastError, &pe) || pe.Value != "boom" || !strings.Contains(string(pe.Stack), "supervisor_test.go") {
+		t.Fatalf("last error = %v", st.LastError)
+	}
+	s.Stop()
+}
+
+func TestSupervisorPolicies(t *testing.T) {
+	s := newTestSupervisor()
+	var always, onFailure, never int32
+	s.GoWithPolicy("always", RestartAlways, func(ctx context.Context) error {
+		atomic.AddInt32(&always, 1)
+		return nil
+	})
+	s.GoWithPolicy("on-failure", RestartOnFailure, func(ctx context.Context) error {
+		atomic.AddInt32(&onFailure, 1)
+		return nil
+	})
+	s.GoWithPolicy("never", RestartNever, func(ctx context.Context) error {
+		atomic.AddInt32(&never, 1)
+		return errors.New("failed")
+	})
+	waitFor(t, func() bool { return atomic.LoadInt32(&always) >= 3 })
+	s.Stop()
+
+	if n := atomic.LoadInt32(&onFailure); n != 1 {
+		t.Fatalf("on-failure runs = %d", n)
+	}
+	if n := atomic.LoadInt32(&never); n != 1 {
+		t.Fatalf("never runs = %d", n)
+	}
+	for _, st := range s.Workers() {
+		if st.Running {
+			t.Fatalf("%s still running", st.Name)
+		}
+	}
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	s := NewSupervisor()
+	s.MinBackoff = time.Millisecond * 10
+	s.MaxBackoff = time.Millisecond * 40
+	var mu sync.Mutex
+	var starts []time.Time
+	s.Go("backoff", func(ctx context.Context) error {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		return errors.New("failed")
+	})
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(starts) >= 5
+	})
+	s.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 间隔依次为 10ms 20ms 40ms 40ms
+	want := []time.Duration{10, 20, 40, 40}
+	for i, w := range want {
+		gap := starts[i+1].Sub(starts[i])
+		if gap < w*time.Millisecond {
+			t.Fatalf("gap %d = %v, want >= %vms", i, gap, w)
+		}
+	}
+}
+
+func TestSupervisorStopOrder(t *testing.T) {
+	s := newTestSupervisor()
+	var mu sync.Mutex
+	var order []string
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		s.Go(name, func(ctx context.Context) error {
+			<-ctx.Done()
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		})
+	}
+	waitFor(t, func() bool {
+		for _, st := range s.Workers() {
+			if !st.Running {
+				return false
+			}
+		}
+		return true
+	})
+	s.Stop()
+
+	if got := strings.Join(order, ","); got != "c,b,a" {
+		t.Fatalf("stop order = %s", got)
+	}
+
+	// 停止后不再接受新的工作协程
+	s.Go("late", func(ctx context.Context) error { return nil })
+	if len(s.Workers()) != 3 {
+		t.Fatal("worker started after stop")
+	}
+}
@@ -0,0 +1,134 @@
+package qlauncher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Unit systemd 服务单元配置
+type Unit struct {
+	Name             string            // 服务名，生成 <Name>.service
+	Description      string            // 服务描述
+	ExecStart        string            // 可执行文件路径
+	Arguments        []string          // 启动参数
+	WorkingDirectory string            // 工作目录
+	User             string            // 运行用户，空则使用root
+	Dependencies     []string          // 依赖的单元，如 network-online.target，写入After和Wants；包含"="的整行原样写入[Unit]
+	Restart          string            // 重启策略 always/on-failure/no，默认always
+	RestartSec       int               // 重启间隔秒数，默认5
+	TimeoutStopSec   int               // 停止超时秒数，0使用systemd默认值
+	WatchdogSec      int               // 看门狗超时秒数，0不启用
+	NotifyAccess     string            // 接受sd_notify的进程 main/all，平滑重启时需为all
+	Env              map[string]string // 环境变量
+}
+
+// Render 生成 systemd 服务单元文件内容
+//
+//	@return string
+func (u Unit) Render() string {
+	var b strings.Builder
+
+	b.WriteString("[Unit]\n")
+	desc := u.Description
+	if desc == "" {
+		desc = u.Name
+	}
+	b.WriteString(fmt.Sprintf("Description=%s\n", desc))
+	var deps, lines []string
+	for _, d := range u.Dependencies {
+		if strings.Contains(d, "=") {
+			lines = append(lines, d)
+		} else if d != "" {
+			deps = append(deps, d)
+		}
+	}
+	if len(deps) > 0 {
+		b.WriteString(fmt.Sprintf("After=%s\n", strings.Join(deps, " ")))
+		b.WriteString(fmt.Sprintf("Wants=%s\n", strings.Join(deps, " ")))
+	}
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString("\n[Service]\n")
+	// 启用看门狗或NotifyAccess时由程序通知就绪
+	if u.WatchdogSec > 0 || u.NotifyAccess != "" {
+		b.WriteString("Type=notify\n")
+	} else {
+		b.WriteString("Type=simple\n")
+	}
+	exec := []string{quoteArg(u.ExecStart)}
+	for _, arg := range u.Arguments {
+		exec = append(exec, quoteArg(arg))
+	}
+	b.WriteString(fmt.Sprintf("ExecStart=%s\n", strings.Join(exec, " ")))
+	if u.WorkingDirectory != "" {
+		b.WriteString(fmt.Sprintf("WorkingDirectory=%s\n", u.WorkingDirectory))
+	}
+	if u.User != "" {
+		b.WriteString(fmt.Sprintf("User=%s\n", u.User))
+	}
+	restart := u.Restart
+	if restart == "" {
+		restart = "always"
+	}
+	b.WriteString(fmt.Sprintf("Restart=%s\n", restart))
+	restartSec := u.RestartSec
+	if restartSec <= 0 {
+		restartSec = 5
+	}
+	b.WriteString(fmt.Sprintf("RestartSec=%d\n", restartSec))
+	if u.TimeoutStopSec > 0 {
+		b.WriteString(fmt.Sprintf("TimeoutStopSec=%d\n", u.TimeoutStopSec))
+	}
+	if u.NotifyAccess != "" {
+		b.WriteString(fmt.Sprintf("NotifyAccess=%s\n", u.NotifyAccess))
+	}
+	if u.WatchdogSec > 0 {
+		b.WriteString(fmt.Sprintf("WatchdogSec=%d\n", u.WatchdogSec))
+	}
+	keys := make([]string, 0, len(u.Env))
+	for k := range u.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(fmt.Sprintf("Environment=%s\n", quoteArg(k+"="+u.Env[k])))
+	}
+
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// Save 将服务单元文件写入指定目录
+//
+//	@param dir 目录，如 /etc/systemd/system
+//	@return string 单元文件路径
+//	@return error
+func (u Unit) Save(dir string) (string, error) {
+	if u.Name == "" {
+		return "", fmt.Errorf("unit name is empty")
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, u.Name+".service")
+	if err := os.WriteFile(file, []byte(u.Render()), 0644); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// quoteArg 含空白或引号的参数加双引号
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\") {
+		return arg
+	}
+	arg = strings.ReplaceAll(arg, `\`, `\\`)
+	arg = strings.ReplaceAll(arg, `"`, `\"`)
+	return `"` + arg + `"`
+}
@@ -0,0 +1,91 @@
+package qlauncher
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUnitSave(t *testing.T) {
+	dir := t.TempDir()
+	u := Unit{
+		Name:             "demo_app",
+		Description:      "Demo App",
+		ExecStart:        "/opt/demo/app",
+		Arguments:        []string{"-c", "/opt/demo/my config.yaml"},
+		WorkingDirectory: "/opt/demo",
+		User:             "demo",
+		Dependencies:     []string{"network-online.target", "mosquitto.service", "Requires=mosquitto.service"},
+		Restart:          "on-failure",
+		RestartSec:       10,
+		TimeoutStopSec:   35,
+		Env:              map[string]string{"B": "2", "A": "hello world"},
+	}
+	file, err := u.Save(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[Unit]
+Description=Demo App
+After=network-online.target mosquitto.service
+Wants=network-online.target mosquitto.service
+Requires=mosquitto.service
+
+[Service]
+Type=simple
+ExecStart=/opt/demo/app -c "/opt/demo/my config.yaml"
+WorkingDirectory=/opt/demo
+User=demo
+Restart=on-failure
+RestartSec=10
+TimeoutStopSec=35
+Environment="A=hello world"
+Environment=B=2
+
+[Install]
+WantedBy=multi-user.target
+`
+	if string(data) != want {
+		t.Fatalf("unit mismatch:\n%s", data)
+	}
+	if !strings.HasSuffix(file, "demo_app.service") {
+		t.Fatalf("unexpected unit file %s", file)
+	}
+}
+
+func TestUnitDefaults(t *testing.T) {
+	out := Unit{Name: "svc", ExecStart: "/usr/bin/svc"}.Render()
+	for _, line := range []string{"Description=svc\n", "Restart=always\n", "RestartSec=5\n"} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("missing %q in\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, "User=") || strings.Contains(out, "After=") {
+		t.Fatalf("unexpected optional lines in\n%s", out)
+	}
+	if strings.Contains(out, "WatchdogSec=") || !strings.Contains(out, "Type=simple\n") {
+		t.Fatalf("unexpected watchdog in\n%s", out)
+	}
+	out = Unit{Name: "svc", ExecStart: "/usr/bin/svc", WatchdogSec: 20}.Render()
+	if !strings.Contains(out, "Type=notify\n") || !strings.Contains(out, "WatchdogSec=20\n") {
+		t.Fatalf("missing watchdog in\n%s", out)
+	}
+	if _, err := (Unit{}).Save(t.TempDir()); err == nil {
+		t.Fatal("Save without name should fail")
+	}
+}
+
+func TestIsCommand(t *testing.T) {
+	for _, arg := range []string{"install", "uninstall", "start", "stop", "restart", "status"} {
+		if !isCommand(arg) {
+			t.Fatalf("%s should be a command", arg)
+		}
+	}
+	if isCommand("-test.v") || isCommand("") {
+		t.Fatal("unexpected command")
+	}
+}
@@ -0,0 +1,156 @@
+package qlauncher
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUpgradeTimeout 默认等待新进程就绪的时间
+const DefaultUpgradeTimeout = time.Second * 30
+
+// 平滑重启时传递给新进程的环境变量
+const (
+	envListeners = "QLAUNCHER_LISTENERS" // 监听器，格式 name=fd,name=fd
+	envLockFD    = "QLAUNCHER_LOCK_FD"   // 单例锁文件
+	envReadyFD   = "QLAUNCHER_READY_FD"  // 就绪通知管道的写端
+)
+
+// inheritance 从父进程继承的文件
+type inheritance struct {
+	mu        sync.Mutex
+	listeners map[string]*os.File
+	lock      *os.File
+	ready     *os.File
+}
+
+var (
+	inheritOnce sync.Once
+	inherited   inheritance
+)
+
+// inherit 解析父进程传递的文件，只在第一次调用时解析，解析后清除环境变量避免传给子进程
+func inherit() *inheritance {
+	inheritOnce.Do(func() {
+		inherited.listeners = map[string]*os.File{}
+		for _, item := range strings.Split(os.Getenv(envListeners), ",") {
+			name, fd, ok := strings.Cut(item, "=")
+			if !ok {
+				continue
+			}
+			if f := fileFromEnv(fd, "listener:"+name); f != nil {
+				inherited.listeners[name] = f
+			}
+		}
+		inherited.lock = fileFromEnv(os.Getenv(envLockFD), "lock")
+		inherited.ready = fileFromEnv(os.Getenv(envReadyFD), "ready")
+		for _, key := range []string{envListeners, envLockFD, envReadyFD} {
+			_ = os.Unsetenv(key)
+		}
+	})
+	return &inherited
+}
+
+func fileFromEnv(value, name string) *os.File {
+	fd, err := strconv.Atoi(value)
+	if err != nil || fd < 3 {
+		return nil
+	}
+	return os.NewFile(uintptr(fd), name)
+}
+
+// takeListener 取出继承的监听器，每个名称只能取一次
+func (in *inheritance) takeListener(name string) (net.Listener, error) {
+	in.mu.Lock()
+	f, ok := in.listeners[name]
+	delete(in.listeners, name)
+	in.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// takeLock 取出继承的锁文件
+func (in *inheritance) takeLock() *os.File {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	f := in.lock
+	in.lock = nil
+	return f
+}
+
+// closeUnclaimed 关闭启动完成后仍未被取用的监听器和锁文件
+func (in *inheritance) closeUnclaimed() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for name, f := range in.listeners {
+		_ = f.Close()
+		delete(in.listeners, name)
+	}
+	if in.lock != nil {
+		_ = in.lock.Close()
+		in.lock = nil
+	}
+}
+
+// notifyReady 通知父进程新进程已就绪，非平滑重启启动时返回false
+func (in *inheritance) notifyReady() bool {
+	in.mu.Lock()
+	f := in.ready
+	in.ready = nil
+	in.mu.Unlock()
+	if f == nil {
+		return false
+	}
+	_, _ = f.Write([]byte{1})
+	_ = f.Close()
+	return true
+}
+
+// Listen 创建具名监听器，平滑重启后的新进程中返回从旧进程继承的同名监听器
+//
+//	平滑重启时所有通过Listen创建的监听器传递给新进程，已建立的连接由旧进程处理完后退出
+//	@param name 名称，同一服务内唯一
+//	@param network tcp、tcp4、tcp6、unix
+//	@param addr 监听地址
+//	@return net.Listener
+//	@return error
+func (l *Launcher) Listen(name, network, addr string) (net.Listener, error) {
+	l.listenMu.Lock()
+	defer l.listenMu.Unlock()
+	if _, exist := l.listeners[name]; exist {
+		return nil, fmt.Errorf("listener %s already exists", name)
+	}
+	ln, err := inherit().takeListener(name)
+	if err != nil {
+		return nil, err
+	}
+	if ln == nil {
+		if ln, err = net.Listen(network, addr); err != nil {
+			return nil, err
+		}
+	}
+	if l.listeners == nil {
+		l.listeners = map[string]net.Listener{}
+	}
+	l.listeners[name] = ln
+	l.listenOrder = append(l.listenOrder, name)
+	return ln, nil
+}
+
+// listenerFile 获取监听器的文件副本，用于传递给新进程
+func listenerFile(ln net.Listener) (*os.File, error) {
+	switch v := ln.(type) {
+	case *net.TCPListener:
+		return v.File()
+	case *net.UnixListener:
+		return v.File()
+	}
+	return nil, fmt.Errorf("unsupported listener %T", ln)
+}
@@ -0,0 +1,133 @@
+//go:build !windows
+
+package qlauncher
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// upgradeSignals 触发平滑重启的信号
+func upgradeSignals() []os.Signal {
+	return []os.Signal{syscall.SIGUSR2}
+}
+
+// Upgrade 平滑重启，启动新的可执行文件并传递监听器和单例锁，新进程就绪后返回
+//
+//	返回nil后旧进程应调用Exit退出；新进程未在UpgradeTimeout内就绪时结束新进程并返回错误，旧进程继续运行
+//	@return error
+func (l *Launcher) Upgrade() error {
+	if !l.upgrading.CompareAndSwap(false, true) {
+		return errors.New("upgrade in progress")
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			l.upgrading.Store(false)
+		}
+	}()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	var files []*os.File
+	defer func() {
+		// 子进程持有副本，父进程中的副本即可关闭
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	env := make([]string, 0, len(os.Environ())+3)
+	for _, kv := range os.Environ() {
+		// 新进程成为主进程后，看门狗心跳由新进程发送
+		if !strings.HasPrefix(kv, "WATCHDOG_PID=") {
+			env = append(env, kv)
+		}
+	}
+
+	l.listenMu.Lock()
+	var items []string
+	for _, name := range l.listenOrder {
+		f, err := listenerFile(l.listeners[name])
+		if err != nil {
+			l.listenMu.Unlock()
+			return fmt.Errorf("listener %s: %w", name, err)
+		}
+		files = append(files, f)
+		items = append(items, fmt.Sprintf("%s=%d", name, 2+len(files)))
+	}
+	l.listenMu.Unlock()
+	if len(items) > 0 {
+		env = append(env, envListeners+"="+strings.Join(items, ","))
+	}
+	if l.pidLock != nil && l.pidLock.file != nil {
+		f, err := dupFile(l.pidLock.file)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		env = append(env, fmt.Sprintf("%s=%d", envLockFD, 2+len(files)))
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	files = append(files, w)
+	env = append(env, fmt.Sprintf("%s=%d", envReadyFD, 2+len(files)))
+
+	cmd := exec.Command(exe, l.args...)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	// 关闭写端，新进程退出时读端能立即返回
+	_ = w.Close()
+	log.Println(fmt.Sprintf("[%s] Upgrading, new pid %d", l.name(), cmd.Process.Pid))
+
+	timeout := l.UpgradeTimeout
+	if timeout <= 0 {
+		timeout = DefaultUpgradeTimeout
+	}
+	_ = r.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1)
+	if _, err = r.Read(buf); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return fmt.Errorf("new process not ready: %w", err)
+	}
+
+	// 监听器已由新进程接管，旧进程关闭时不能删除unix socket文件
+	l.listenMu.Lock()
+	for _, ln := range l.listeners {
+		if ul, is := ln.(*net.UnixListener); is {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	l.listenMu.Unlock()
+	l.handedOver.Store(true)
+	ok = true
+	log.Println(fmt.Sprintf("[%s] Upgraded, new pid %d ready", l.name(), cmd.Process.Pid))
+	_ = cmd.Process.Release()
+	return nil
+}
+
+// dupFile 复制文件描述符
+func dupFile(f *os.File) (*os.File, error) {
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
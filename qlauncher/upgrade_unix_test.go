@@ -0,0 +1,98 @@
+//go:build !windows
+
+package qlauncher
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestUpgradeHelper 平滑重启测试中由新进程执行
+func TestUpgradeHelper(t *testing.T) {
+	if os.Getenv("QLAUNCHER_UPGRADE_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	in := inherit()
+	ln, err := in.takeListener("web")
+	if err != nil || ln == nil {
+		os.Exit(2)
+	}
+	if f := in.takeLock(); f != nil {
+		_ = (&PIDLock{file: f}).writePID()
+	}
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("new"))
+	}))
+	in.notifyReady()
+	time.Sleep(time.Second * 10)
+	os.Exit(0)
+}
+
+func TestUpgrade(t *testing.T) {
+	l := New(nil, nil)
+	l.args = []string{"-test.run=^TestUpgradeHelper$"}
+	l.UpgradeTimeout = time.Second * 5
+
+	file := filepath.Join(t.TempDir(), "app.pid")
+	lock, err := AcquireLock(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.pidLock = lock
+	ln, err := l.Listen("web", "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = l.Listen("web", "tcp", "127.0.0.1:0"); err == nil {
+		t.Fatal("duplicate listener name should fail")
+	}
+	addr := ln.Addr().String()
+
+	t.Setenv("QLAUNCHER_UPGRADE_HELPER", "1")
+	if err = l.Upgrade(); err != nil {
+		t.Fatal(err)
+	}
+	// 旧进程退出
+	_ = ln.Close()
+	lock.detach()
+
+	pid, err := ReadLockPID(file)
+	if err != nil || pid == os.Getpid() {
+		t.Fatalf("lock pid = %d %v", pid, err)
+	}
+	defer syscall.Kill(pid, syscall.SIGKILL)
+	var locked *LockedError
+	if _, err = AcquireLock(file); !errors.As(err, &locked) || locked.PID != pid {
+		t.Fatalf("lock err = %v", err)
+	}
+
+	// 新进程接管监听端口
+	resp, err := http.Get("http://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "new" {
+		t.Fatalf("body = %s", body)
+	}
+}
+
+func TestUpgradeNotReady(t *testing.T) {
+	l := New(nil, nil)
+	// 未设置环境变量时新进程跳过测试直接退出，不通知就绪
+	l.args = []string{"-test.run=^TestUpgradeHelper$"}
+	l.UpgradeTimeout = time.Second * 5
+	if err := l.Upgrade(); err == nil {
+		t.Fatal("upgrade should fail")
+	}
+	if l.handedOver.Load() || l.upgrading.Load() {
+		t.Fatal("state should be reset")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package qlauncher
+
+import (
+	"errors"
+	"os"
+)
+
+// upgradeSignals Windows不支持通过信号触发平滑重启
+func upgradeSignals() []os.Signal {
+	return nil
+}
+
+// Upgrade Windows不支持平滑重启
+//
+//	@return error
+func (l *Launcher) Upgrade() error {
+	return errors.New("hot restart is not supported on windows")
+}
@@ -0,0 +1,415 @@
+package qmonitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAlertInterval 默认的规则评估间隔
+const DefaultAlertInterval = time.Second * 30
+
+// AlertState 告警状态
+type AlertState int
+
+const (
+	AlertInactive AlertState = iota // 条件不满足
+	AlertPending                    // 条件满足，但持续时间未达到For
+	AlertFiring                     // 告警中
+	AlertResolved                   // 已恢复，仅出现在通知中
+)
+
+func (s AlertState) String() string {
+	switch s {
+	case AlertPending:
+		return "pending"
+	case AlertFiring:
+		return "firing"
+	case AlertResolved:
+		return "resolved"
+	default:
+		return "inactive"
+	}
+}
+
+func (s AlertState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// AlertRule 阈值告警规则
+type AlertRule struct {
+	Name      string            // 名称，唯一
+	Metric    string            // 指标名称，见MetricValues、ProbeValues
+	Op        string            // 比较运算符：> >= < <= == !=
+	Threshold float64           // 阈值
+	For       time.Duration     // 条件持续多久后告警，0为立即告警
+	Labels    map[string]string // 附加到告警的标签
+	Summary   string            // 告警描述，为空时按规则生成
+}
+
+// ParseAlertRule 解析规则表达式，如 "mem > 90% for 5m"、"disk:/data >= 95"、"probe:db == 0 for 1m"
+//
+//	@param name 规则名称
+//	@param expr 表达式，格式为 <指标> <运算符> <阈值>[%] [for <持续时间>]
+//	@return AlertRule
+//	@return error
+func ParseAlertRule(name, expr string) (AlertRule, error) {
+	rule := AlertRule{Name: name}
+	fields := strings.Fields(expr)
+	if len(fields) != 3 && len(fields) != 5 {
+		return rule, fmt.Errorf("invalid alert rule %q", expr)
+	}
+	rule.Metric, rule.Op = fields[0], fields[1]
+	if _, ok := compareOps[rule.Op]; !ok {
+		return rule, fmt.Errorf("invalid operator %q in alert rule %q", rule.Op, expr)
+	}
+	var err error
+	if rule.Threshold, err = strconv.ParseFloat(strings.TrimSuffix(fields[2], "%"), 64); err != nil {
+		return rule, fmt.Errorf("invalid threshold in alert rule %q", expr)
+	}
+	if len(fields) == 5 {
+		if fields[3] != "for" {
+			return rule, fmt.Errorf("invalid alert rule %q", expr)
+		}
+		if rule.For, err = time.ParseDuration(fields[4]); err != nil || rule.For < 0 {
+			return rule, fmt.Errorf("invalid duration in alert rule %q", expr)
+		}
+	}
+	return rule, nil
+}
+
+// MustParseAlertRule 解析规则表达式，失败时panic
+//
+//	@param name
+//	@param expr
+//	@return AlertRule
+func MustParseAlertRule(name, expr string) AlertRule {
+	rule, err := ParseAlertRule(name, expr)
+	if err != nil {
+		panic(err)
+	}
+	return rule
+}
+
+// String 规则表达式
+func (r AlertRule) String() string {
+	s := fmt.Sprintf("%s %s %s", r.Metric, r.Op, strconv.FormatFloat(r.Threshold, 'f', -1, 64))
+	if r.For > 0 {
+		s += " for " + r.For.String()
+	}
+	return s
+}
+
+var compareOps = map[string]func(a, b float64) bool{
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// Alert 告警
+type Alert struct {
+	Rule       string            `json:"rule"`
+	Metric     string            `json:"metric"`
+	State      AlertState        `json:"state"`
+	Value      float64           `json:"value"` // 最近一次评估时的指标值
+	Threshold  float64           `json:"threshold"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Summary    string            `json:"summary"`
+	ActiveAt   time.Time         `json:"activeAt"`             // 条件开始满足的时间
+	FiredAt    *time.Time        `json:"firedAt,omitempty"`    // 开始告警的时间，待告警时为nil
+	ResolvedAt *time.Time        `json:"resolvedAt,omitempty"` // 恢复的时间，未恢复时为nil
+}
+
+// AlertNotifier 告警通知渠道
+type AlertNotifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// AlertNotifierFunc 以方法实现的通知渠道
+type AlertNotifierFunc func(ctx context.Context, alert Alert) error
+
+func (f AlertNotifierFunc) Notify(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+// AlertManager 告警规则引擎，按指标评估规则，在开始告警和恢复时通知
+//
+// 同一规则告警期间不重复通知，除非设置了RepeatInterval
+type AlertManager struct {
+	Interval       time.Duration // Run的评估间隔，0使用DefaultAlertInterval
+	RepeatInterval time.Duration // 告警期间重复通知的间隔，0不重复
+	NotifyTimeout  time.Duration // 每个通知的发送超时，0使用DefaultNotifyTimeout
+
+	mu        sync.Mutex
+	rules     []AlertRule
+	alerts    map[string]*alertEntry
+	notifiers []AlertNotifier
+	queue     []Alert // 待发送的通知，按产生的顺序发送
+	sending   bool    // 是否有Evaluate正在发送queue中的通知
+	now       func() time.Time
+}
+
+type alertEntry struct {
+	alert      Alert
+	notifiedAt time.Time
+}
+
+// NewAlertManager 创建告警规则引擎
+//
+//	@param rules
+//	@return *AlertManager
+func NewAlertManager(rules ...AlertRule) *AlertManager {
+	return &AlertManager{rules: rules, alerts: map[string]*alertEntry{}, now: time.Now}
+}
+
+// AddRule 添加规则
+//
+//	@param rule
+//	@return error 名称为空、重复或运算符无效时返回错误
+func (m *AlertManager) AddRule(rule AlertRule) error {
+	if rule.Name == "" || rule.Metric == "" {
+		return errors.New("alert rule name and metric are required")
+	}
+	if _, ok := compareOps[rule.Op]; !ok {
+		return fmt.Errorf("invalid operator %q in alert rule %s", rule.Op, rule.Name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.rules {
+		if r.Name == rule.Name {
+			return fmt.Errorf("alert rule %s already exists", rule.Name)
+		}
+	}
+	m.rules = append(m.rules, rule)
+	return nil
+}
+
+// AddNotifier 添加通知渠道
+//
+//	@param n
+func (m *AlertManager) AddNotifier(n AlertNotifier) {
+	m.mu.Lock()
+	m.notifiers = append(m.notifiers, n)
+	m.mu.Unlock()
+}
+
+// Evaluate 按指标值评估所有规则并发送通知，缺少指标的规则保持原状态
+//
+// 通知按产生的顺序逐个发送，同一规则的告警和恢复不会乱序；
+// 其他Evaluate正在发送时，本次的通知加入队列由其发送，不等待发送完成；
+// 队列中的通知可能来自其他调用方，因此每个通知使用独立的超时上下文发送，不受ctx取消的影响
+//
+//	@param ctx 保留参数，不用于发送通知
+//	@param values 指标值
+//	@return []Alert 本次产生的通知
+func (m *AlertManager) Evaluate(ctx context.Context, values map[string]float64) []Alert {
+	m.mu.Lock()
+	now := m.now()
+	var notify []Alert
+	for _, rule := range m.rules {
+		value, ok := values[rule.Metric]
+		if !ok {
+			continue
+		}
+		op := compareOps[rule.Op]
+		if op == nil {
+			continue
+		}
+		if a, send := m.step(rule, value, op(value, rule.Threshold), now); send {
+			notify = append(notify, a)
+		}
+	}
+	m.queue = append(m.queue, notify...)
+	if m.sending {
+		m.mu.Unlock()
+		return notify
+	}
+	m.sending = true
+	for len(m.queue) > 0 {
+		a := m.queue[0]
+		m.queue = m.queue[1:]
+		notifiers := append([]AlertNotifier(nil), m.notifiers...)
+		timeout := durationOr(m.NotifyTimeout, DefaultNotifyTimeout)
+		m.mu.Unlock()
+		for _, n := range notifiers {
+			nctx, cancel := context.WithTimeout(context.Background(), timeout)
+			if err := n.Notify(nctx, a); err != nil {
+				log.Println(fmt.Sprintf("[Alert] Notify %s error, %s", a.Rule, err))
+			}
+			cancel()
+		}
+		m.mu.Lock()
+	}
+	m.sending = false
+	m.mu.Unlock()
+	return notify
+}
+
+// step 推进单条规则的状态，返回需要发送的通知
+func (m *AlertManager) step(rule AlertRule, value float64, active bool, now time.Time) (Alert, bool) {
+	e := m.alerts[rule.Name]
+	if !active {
+		if e == nil {
+			return Alert{}, false
+		}
+		delete(m.alerts, rule.Name)
+		if e.alert.State != AlertFiring {
+			return Alert{}, false
+		}
+		a := e.alert
+		a.State = AlertResolved
+		a.Value = value
+		a.ResolvedAt = &now
+		return a.clone(), true
+	}
+
+	if e == nil {
+		e = &alertEntry{alert: Alert{
+			Rule:      rule.Name,
+			Metric:    rule.Metric,
+			State:     AlertPending,
+			Threshold: rule.Threshold,
+			Labels:    copyLabels(rule.Labels),
+			Summary:   rule.Summary,
+			ActiveAt:  now,
+		}}
+		if e.alert.Summary == "" {
+			e.alert.Summary = rule.String()
+		}
+		m.alerts[rule.Name] = e
+	}
+	e.alert.Value = value
+	switch e.alert.State {
+	case AlertPending:
+		if now.Sub(e.alert.ActiveAt) < rule.For {
+			return Alert{}, false
+		}
+		e.alert.State = AlertFiring
+		firedAt := now
+		e.alert.FiredAt = &firedAt
+	case AlertFiring:
+		if m.RepeatInterval <= 0 || now.Sub(e.notifiedAt) < m.RepeatInterval {
+			return Alert{}, false
+		}
+	}
+	e.notifiedAt = now
+	return e.alert.clone(), true
+}
+
+// Alerts 获取所有待告警和告警中的告警，按规则名称排序
+//
+//	@return []Alert
+func (m *AlertManager) Alerts() []Alert {
+	m.mu.Lock()
+	list := make([]Alert, 0, len(m.alerts))
+	for _, e := range m.alerts {
+		list = append(list, e.alert)
+	}
+	m.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Rule < list[j].Rule
+	})
+	return list
+}
+
+// Run 按间隔获取指标并评估，阻塞直到ctx取消
+//
+//	@param ctx
+//	@param source 指标来源
+func (m *AlertManager) Run(ctx context.Context, source func(ctx context.Context) (map[string]float64, error)) {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = DefaultAlertInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		values, err := source(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Println(fmt.Sprintf("[Alert] Source error, %s", err))
+			}
+		} else {
+			m.Evaluate(ctx, values)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MetricValues 将主机指标转换为规则可用的指标值
+//
+// 指标名称：cpu、mem、swap(百分比)，load1、load5、load15，
+// disk:<挂载点>(百分比)，net:<网卡>:rx、net:<网卡>:tx(字节/秒)
+//
+//	@param m
+//	@return map[string]float64
+func MetricValues(m HostMetrics) map[string]float64 {
+	values := map[string]float64{
+		"cpu":    m.CPUPercent,
+		"mem":    m.MemPercent,
+		"load1":  m.Load1,
+		"load5":  m.Load5,
+		"load15": m.Load15,
+	}
+	if m.SwapTotal > 0 {
+		values["swap"] = float64(m.SwapUsed) / float64(m.SwapTotal) * 100
+	}
+	for _, d := range m.Disks {
+		values["disk:"+d.Path] = d.UsedPercent
+	}
+	for _, n := range m.Networks {
+		values["net:"+n.Name+":rx"] = n.RxRate
+		values["net:"+n.Name+":tx"] = n.TxRate
+	}
+	return values
+}
+
+// ProbeValues 将探测状态转换为规则可用的指标值
+//
+// 指标名称为 probe:<名称>，健康为1，不健康为0，未判定的不输出
+//
+//	@param list
+//	@return map[string]float64
+func ProbeValues(list []ProbeStatus) map[string]float64 {
+	values := map[string]float64{}
+	for _, st := range list {
+		switch st.State {
+		case ProbeHealthy:
+			values["probe:"+st.Name] = 1
+		case ProbeUnhealthy:
+			values["probe:"+st.Name] = 0
+		}
+	}
+	return values
+}
+
+// clone 复制告警，标签不与规则和其他通知共用
+func (a Alert) clone() Alert {
+	a.Labels = copyLabels(a.Labels)
+	return a
+}
+
+// copyLabels 复制标签，nil保持为nil
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	c := make(map[string]string, len(labels))
+	for k, v := range labels {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,299 @@
+package qmonitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	r, err := ParseAlertRule("mem_high", "mem > 90% for 5m")
+	if err != nil || r.Metric != "mem" || r.Op != ">" || r.Threshold != 90 || r.For != time.Minute*5 {
+		t.Fatalf("rule = %+v %v", r, err)
+	}
+	if r.String() != "mem > 90 for 5m0s" {
+		t.Fatalf("string = %s", r)
+	}
+	r, err = ParseAlertRule("disk", "disk:/data >= 95.5")
+	if err != nil || r.Metric != "disk:/data" || r.Threshold != 95.5 || r.For != 0 {
+		t.Fatalf("rule = %+v %v", r, err)
+	}
+	for _, expr := range []string{"", "mem > ", "mem => 90", "mem > x", "mem > 90 during 5m", "mem > 90 for x", "mem > 90 for -1m"} {
+		if _, err = ParseAlertRule("bad", expr); err == nil {
+			t.Fatalf("%q should fail", expr)
+		}
+	}
+}
+
+// alertRecorder 记录收到的通知，格式为 规则:状态
+type alertRecorder struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (r *alertRecorder) Notify(ctx context.Context, a Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, a)
+	return nil
+}
+
+func (r *alertRecorder) take() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var list []string
+	for _, a := range r.alerts {
+		list = append(list, a.Rule+":"+a.State.String())
+	}
+	r.alerts = nil
+	return strings.Join(list, " ")
+}
+
+func TestAlertManager(t *testing.T) {
+	m := NewAlertManager(MustParseAlertRule("mem_high", "mem > 90% for 5m"), MustParseAlertRule("db_down", "probe:db == 0"))
+	now := time.Unix(1700000000, 0)
+	m.now = func() time.Time { return now }
+	var rec alertRecorder
+	m.AddNotifier(&rec)
+	m.AddNotifier(AlertNotifierFunc(func(ctx context.Context, a Alert) error {
+		return errors.New("channel down")
+	}))
+	ctx := context.Background()
+	eval := func(mem float64, probes ...ProbeStatus) string {
+		values := MetricValues(HostMetrics{MemPercent: mem})
+		for k, v := range ProbeValues(probes) {
+			values[k] = v
+		}
+		m.Evaluate(ctx, values)
+		return rec.take()
+	}
+
+	// 条件满足但未持续5分钟
+	if got := eval(95); got != "" || m.Alerts()[0].State != AlertPending {
+		t.Fatalf("got = %s %+v", got, m.Alerts())
+	}
+	now = now.Add(time.Minute * 3)
+	// 中途恢复，重新计时，不产生通知
+	if got := eval(50); got != "" || len(m.Alerts()) != 0 {
+		t.Fatalf("got = %s", got)
+	}
+	eval(95)
+	now = now.Add(time.Minute * 5)
+	if got := eval(96); got != "mem_high:firing" {
+		t.Fatalf("got = %s", got)
+	}
+	a := m.Alerts()[0]
+	if a.State != AlertFiring || a.Value != 96 || a.Summary != "mem > 90 for 5m0s" || a.FiredAt.Sub(a.ActiveAt) != time.Minute*5 {
+		t.Fatalf("alert = %+v", a)
+	}
+	// 告警期间不重复通知
+	now = now.Add(time.Hour)
+	if got := eval(97); got != "" {
+		t.Fatalf("got = %s", got)
+	}
+	// 未判定的探测没有指标，规则保持原状态
+	if got := eval(97, ProbeStatus{Name: "db", State: ProbeUnknown}); got != "" {
+		t.Fatalf("got = %s", got)
+	}
+	if got := eval(97, ProbeStatus{Name: "db", State: ProbeUnhealthy}); got != "db_down:firing" {
+		t.Fatalf("got = %s", got)
+	}
+	now = now.Add(time.Minute)
+	if got := eval(80, ProbeStatus{Name: "db", State: ProbeHealthy}); got != "mem_high:resolved db_down:resolved" {
+		t.Fatalf("got = %s", got)
+	}
+	if len(m.Alerts()) != 0 {
+		t.Fatalf("alerts = %+v", m.Alerts())
+	}
+}
+
+func TestAlertManagerRepeat(t *testing.T) {
+	m := NewAlertManager()
+	if err := m.AddRule(AlertRule{Name: "cpu", Metric: "cpu", Op: ">=", Threshold: 80, Labels: map[string]string{"host": "gw1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddRule(AlertRule{Name: "cpu", Metric: "cpu", Op: ">"}); err == nil {
+		t.Fatal("duplicate rule should fail")
+	}
+	if err := m.AddRule(AlertRule{Name: "x", Metric: "cpu", Op: "=>"}); err == nil {
+		t.Fatal("invalid operator should fail")
+	}
+	now := time.Unix(1700000000, 0)
+	m.now = func() time.Time { return now }
+	m.RepeatInterval = time.Minute * 10
+
+	var sent []Alert
+	for i := 0; i < 5; i++ {
+		sent = append(sent, m.Evaluate(context.Background(), map[string]float64{"cpu": 80})...)
+		now = now.Add(time.Minute * 4)
+	}
+	// 0、12、16分钟中的0和12分钟发送
+	if len(sent) != 2 || sent[0].Labels["host"] != "gw1" || sent[1].State != AlertFiring {
+		t.Fatalf("sent = %+v", sent)
+	}
+}
+
+func TestAlertManagerOrder(t *testing.T) {
+	rule := MustParseAlertRule("cpu", "cpu > 80")
+	rule.Labels = map[string]string{"host": "a"}
+	m := NewAlertManager(rule)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var got []string
+	var errs []error
+	m.AddNotifier(AlertNotifierFunc(func(ctx context.Context, a Alert) error {
+		if a.State == AlertFiring {
+			close(entered)
+			<-release
+		}
+		data, _ := json.Marshal(a)
+		mu.Lock()
+		got = append(got, string(data))
+		errs = append(errs, ctx.Err())
+		mu.Unlock()
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sent := m.Evaluate(ctx, map[string]float64{"cpu": 90})
+		sent[0].Labels["host"] = "b"
+		close(done)
+	}()
+	<-entered
+	// 告警的通知尚未发送完成，恢复的通知排队，由前一个Evaluate按顺序发送
+	if sent := m.Evaluate(context.Background(), map[string]float64{"cpu": 10}); len(sent) != 1 || sent[0].State != AlertResolved {
+		t.Fatalf("sent = %+v", sent)
+	}
+	// 发送队列的调用方取消ctx不影响排队的通知
+	cancel()
+	close(release)
+	<-done
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("errs = %v", errs)
+	}
+	if rule.Labels["host"] != "a" || !strings.Contains(got[1], `"host":"a"`) {
+		t.Fatalf("labels = %v, got = %v", rule.Labels, got)
+	}
+	if len(got) != 2 || !strings.Contains(got[0], `"state":"firing"`) || !strings.Contains(got[1], `"state":"resolved"`) {
+		t.Fatalf("got = %v", got)
+	}
+	if strings.Contains(got[0], "resolvedAt") || !strings.Contains(got[0], "firedAt") || !strings.Contains(got[1], "resolvedAt") {
+		t.Fatalf("got = %v", got)
+	}
+}
+
+func TestAlertManagerRun(t *testing.T) {
+	m := NewAlertManager(MustParseAlertRule("load", "load1 > 4"))
+	m.Interval = time.Millisecond * 10
+	got := make(chan Alert, 10)
+	m.AddNotifier(AlertNotifierFunc(func(ctx context.Context, a Alert) error {
+		got <- a
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	calls := 0
+	go func() {
+		m.Run(ctx, func(ctx context.Context) (map[string]float64, error) {
+			if calls++; calls == 1 {
+				return nil, errors.New("source down")
+			}
+			return map[string]float64{"load1": 5}, nil
+		})
+		close(done)
+	}()
+	select {
+	case a := <-got:
+		if a.Rule != "load" || a.Value != 5 {
+			t.Fatalf("alert = %+v", a)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("timeout")
+	}
+	cancel()
+	<-done
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var raw map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&raw)
+		if raw["state"] != "firing" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- Alert{Rule: raw["rule"].(string)}
+	}))
+	defer srv.Close()
+
+	alert := Alert{Rule: "mem_high", State: AlertFiring, Value: 95}
+	n := &WebhookNotifier{URL: srv.URL, Header: map[string]string{"Authorization": "Bearer t"}}
+	if err := n.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	if a := <-received; a.Rule != "mem_high" {
+		t.Fatalf("alert = %+v", a)
+	}
+	n.Header = nil
+	if err := n.Notify(context.Background(), alert); err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "alerts", "alerts.log")
+	n := &FileNotifier{Path: file}
+	for _, state := range []AlertState{AlertFiring, AlertResolved} {
+		if err := n.Notify(context.Background(), Alert{Rule: "disk", State: state}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var states []string
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		var raw map[string]any
+		if err = json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, raw["state"].(string))
+	}
+	if strings.Join(states, ",") != "firing,resolved" {
+		t.Fatalf("states = %v", states)
+	}
+}
+
+func TestEmqxNotifier(t *testing.T) {
+	f := newFakeEmqx(t)
+	n := &EmqxNotifier{Client: f.client(), Topic: "alerts/gw1/{rule}", QoS: 1}
+	if err := n.Notify(context.Background(), Alert{Rule: "mem_high", State: AlertFiring}); err != nil {
+		t.Fatal(err)
+	}
+	msg := f.published[0]
+	if msg.Topic != "alerts/gw1/mem_high" || msg.QoS != 1 || !strings.Contains(msg.Payload, `"state":"firing"`) {
+		t.Fatalf("message = %+v", msg)
+	}
+	n.Client.Password = "wrong"
+	if err := n.Notify(context.Background(), Alert{Rule: "mem_high"}); !errors.Is(err, ErrEmqxUnauthorized) {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -1,34 +1,399 @@
 package qmonitor
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// CheckEmqxClientExist 检测Emqx客户端是否存在
-func CheckEmqxClientExist(emqxAddr string, userName string, password string, clientID string) bool {
-	url := fmt.Sprintf("%s/api/v5/clients/%s", emqxAddr, clientID)
+// DefaultEmqxTimeout 默认的EMQX接口请求超时时间
+const DefaultEmqxTimeout = time.Second * 10
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return false
+// DefaultEmqxPageLimit 默认的分页大小
+const DefaultEmqxPageLimit = 100
+
+var (
+	// ErrEmqxNotFound 客户端或资源不存在
+	ErrEmqxNotFound = errors.New("emqx: not found")
+	// ErrEmqxUnauthorized 认证失败
+	ErrEmqxUnauthorized = errors.New("emqx: unauthorized")
+)
+
+// EmqxError EMQX接口返回的错误
+type EmqxError struct {
+	StatusCode int    `json:"-"`    // HTTP状态码
+	Code       string `json:"code"` // EMQX错误码，如CLIENTID_NOT_FOUND
+	Message    string `json:"message"`
+}
+
+func (e *EmqxError) Error() string {
+	if e.Code == "" && e.Message == "" {
+		return fmt.Sprintf("emqx: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("emqx: status %d, %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is 404匹配ErrEmqxNotFound，401/403匹配ErrEmqxUnauthorized
+func (e *EmqxError) Is(target error) bool {
+	switch target {
+	case ErrEmqxNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrEmqxUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// EmqxClientInfo 客户端连接信息
+type EmqxClientInfo struct {
+	ClientID         string    `json:"clientid"`
+	Username         string    `json:"username"`
+	Node             string    `json:"node"`
+	Connected        bool      `json:"connected"`
+	IPAddress        string    `json:"ip_address"`
+	Port             int       `json:"port"`
+	ProtoName        string    `json:"proto_name"`
+	ProtoVer         int       `json:"proto_ver"`
+	KeepAlive        int       `json:"keepalive"`
+	CleanStart       bool      `json:"clean_start"`
+	ConnectedAt      time.Time `json:"connected_at"`
+	CreatedAt        time.Time `json:"created_at"`
+	SubscriptionsCnt int       `json:"subscriptions_cnt"`
+	RecvMsg          int64     `json:"recv_msg"`
+	SendMsg          int64     `json:"send_msg"`
+}
+
+// EmqxClientFilter 查询客户端的过滤条件，为空的字段不参与过滤
+type EmqxClientFilter struct {
+	Node         string
+	Username     string
+	ClientID     string
+	LikeClientID string // 客户端ID模糊匹配
+	IPAddress    string
+	ConnState    string // connected、idle、disconnected
+}
+
+func (f EmqxClientFilter) values() url.Values {
+	q := url.Values{}
+	setQuery(q, "node", f.Node)
+	setQuery(q, "username", f.Username)
+	setQuery(q, "clientid", f.ClientID)
+	setQuery(q, "like_clientid", f.LikeClientID)
+	setQuery(q, "ip_address", f.IPAddress)
+	setQuery(q, "conn_state", f.ConnState)
+	return q
+}
+
+// EmqxSubscription 订阅信息
+type EmqxSubscription struct {
+	ClientID string `json:"clientid"`
+	Topic    string `json:"topic"`
+	QoS      int    `json:"qos"`
+	Node     string `json:"node"`
+}
+
+// EmqxSubscriptionFilter 查询订阅的过滤条件，为空的字段不参与过滤
+type EmqxSubscriptionFilter struct {
+	ClientID   string
+	Topic      string // 精确匹配
+	MatchTopic string // 按通配符匹配
+	QoS        *int
+}
+
+func (f EmqxSubscriptionFilter) values() url.Values {
+	q := url.Values{}
+	setQuery(q, "clientid", f.ClientID)
+	setQuery(q, "topic", f.Topic)
+	setQuery(q, "match_topic", f.MatchTopic)
+	if f.QoS != nil {
+		q.Set("qos", strconv.Itoa(*f.QoS))
+	}
+	return q
+}
+
+// EmqxTopic 主题路由
+type EmqxTopic struct {
+	Topic string `json:"topic"`
+	Node  string `json:"node"`
+}
+
+// EmqxPage 分页信息，Page从1开始
+type EmqxPage struct {
+	Page    int  `json:"page"`
+	Limit   int  `json:"limit"`
+	Count   int  `json:"count"`
+	HasNext bool `json:"hasnext"`
+}
+
+// EmqxStats 集群统计，键如connections.count、topics.count、subscriptions.count
+type EmqxStats map[string]int64
+
+// EmqxClient EMQX v5 管理接口客户端
+type EmqxClient struct {
+	Addr      string // 接口地址，如http://127.0.0.1:18083
+	Username  string // API Key或Dashboard用户名
+	Password  string
+	Timeout   time.Duration // 单次请求超时，0使用DefaultEmqxTimeout
+	HTTP      *http.Client  // 为空时使用http.DefaultClient
+	PageLimit int           // AllClients翻页查询的每页数量，0使用DefaultEmqxPageLimit
+}
+
+// NewEmqxClient 创建EMQX管理接口客户端
+//
+//	@param addr 接口地址，如http://127.0.0.1:18083
+//	@param username
+//	@param password
+//	@return *EmqxClient
+func NewEmqxClient(addr, username, password string) *EmqxClient {
+	return &EmqxClient{Addr: strings.TrimRight(addr, "/"), Username: username, Password: password, Timeout: DefaultEmqxTimeout}
+}
+
+// Client 获取客户端信息，不存在时返回ErrEmqxNotFound
+//
+//	@param ctx
+//	@param clientID
+//	@return EmqxClientInfo
+//	@return error
+func (c *EmqxClient) Client(ctx context.Context, clientID string) (EmqxClientInfo, error) {
+	var info EmqxClientInfo
+	err := c.do(ctx, http.MethodGet, "/clients/"+url.PathEscape(clientID), nil, &info)
+	return info, err
+}
+
+// ClientExists 客户端是否存在
+//
+//	@param ctx
+//	@param clientID
+//	@return bool
+//	@return error 请求失败，客户端不存在时不返回错误
+func (c *EmqxClient) ClientExists(ctx context.Context, clientID string) (bool, error) {
+	_, err := c.Client(ctx, clientID)
+	if errors.Is(err, ErrEmqxNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Clients 分页查询客户端
+//
+//	@param ctx
+//	@param filter
+//	@param page 页码，从1开始
+//	@param limit 每页数量，0使用DefaultEmqxPageLimit
+//	@return []EmqxClientInfo
+//	@return EmqxPage
+//	@return error
+func (c *EmqxClient) Clients(ctx context.Context, filter EmqxClientFilter, page, limit int) ([]EmqxClientInfo, EmqxPage, error) {
+	var list []EmqxClientInfo
+	meta, err := c.list(ctx, "/clients", filter.values(), page, limit, &list)
+	return list, meta, err
+}
+
+// AllClients 翻页查询所有符合条件的客户端，每页数量为PageLimit
+//
+//	@param ctx
+//	@param filter
+//	@return []EmqxClientInfo
+//	@return error
+func (c *EmqxClient) AllClients(ctx context.Context, filter EmqxClientFilter) ([]EmqxClientInfo, error) {
+	var all []EmqxClientInfo
+	for page := 1; ; page++ {
+		list, meta, err := c.Clients(ctx, filter, page, c.PageLimit)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, list...)
+		if !meta.HasNext || len(list) == 0 {
+			return all, nil
+		}
+	}
+}
+
+// Kick 踢除客户端
+//
+//	@param ctx
+//	@param clientID
+//	@return error 客户端不存在时返回ErrEmqxNotFound
+func (c *EmqxClient) Kick(ctx context.Context, clientID string) error {
+	return c.do(ctx, http.MethodDelete, "/clients/"+url.PathEscape(clientID), nil, nil)
+}
+
+// ClientSubscriptions 获取客户端的所有订阅
+//
+//	@param ctx
+//	@param clientID
+//	@return []EmqxSubscription
+//	@return error
+func (c *EmqxClient) ClientSubscriptions(ctx context.Context, clientID string) ([]EmqxSubscription, error) {
+	var list []EmqxSubscription
+	err := c.do(ctx, http.MethodGet, "/clients/"+url.PathEscape(clientID)+"/subscriptions", nil, &list)
+	return list, err
+}
+
+// Subscriptions 分页查询订阅
+//
+//	@param ctx
+//	@param filter
+//	@param page 页码，从1开始
+//	@param limit 每页数量，0使用DefaultEmqxPageLimit
+//	@return []EmqxSubscription
+//	@return EmqxPage
+//	@return error
+func (c *EmqxClient) Subscriptions(ctx context.Context, filter EmqxSubscriptionFilter, page, limit int) ([]EmqxSubscription, EmqxPage, error) {
+	var list []EmqxSubscription
+	meta, err := c.list(ctx, "/subscriptions", filter.values(), page, limit, &list)
+	return list, meta, err
+}
+
+// Topics 分页查询主题路由
+//
+//	@param ctx
+//	@param topic 主题，为空时查询所有
+//	@param page 页码，从1开始
+//	@param limit 每页数量，0使用DefaultEmqxPageLimit
+//	@return []EmqxTopic
+//	@return EmqxPage
+//	@return error
+func (c *EmqxClient) Topics(ctx context.Context, topic string, page, limit int) ([]EmqxTopic, EmqxPage, error) {
+	q := url.Values{}
+	setQuery(q, "topic", topic)
+	var list []EmqxTopic
+	meta, err := c.list(ctx, "/topics", q, page, limit, &list)
+	return list, meta, err
+}
+
+// Stats 获取集群汇总的统计数据
+//
+//	@param ctx
+//	@return EmqxStats
+//	@return error
+func (c *EmqxClient) Stats(ctx context.Context) (EmqxStats, error) {
+	stats := EmqxStats{}
+	err := c.do(ctx, http.MethodGet, "/stats?aggregate=true", nil, &stats)
+	return stats, err
+}
+
+// Publish 通过接口发布消息
+//
+//	@param ctx
+//	@param topic
+//	@param payload
+//	@param qos
+//	@param retain
+//	@return string 消息ID
+//	@return error
+func (c *EmqxClient) Publish(ctx context.Context, topic string, payload []byte, qos int, retain bool) (string, error) {
+	req := map[string]any{
+		"topic":            topic,
+		"payload":          base64.StdEncoding.EncodeToString(payload),
+		"payload_encoding": "base64",
+		"qos":              qos,
+		"retain":           retain,
+	}
+	var resp struct {
+		ID string `json:"id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/publish", req, &resp)
+	return resp.ID, err
+}
+
+// list 请求分页接口
+func (c *EmqxClient) list(ctx context.Context, path string, q url.Values, page, limit int, data any) (EmqxPage, error) {
+	if page <= 0 {
+		page = 1
 	}
+	if limit <= 0 {
+		limit = DefaultEmqxPageLimit
+	}
+	q.Set("page", strconv.Itoa(page))
+	q.Set("limit", strconv.Itoa(limit))
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+		Meta EmqxPage        `json:"meta"`
+	}
+	if err := c.do(ctx, http.MethodGet, path+"?"+q.Encode(), nil, &resp); err != nil {
+		return EmqxPage{}, err
+	}
+	if len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, data); err != nil {
+			return EmqxPage{}, fmt.Errorf("emqx: decode %s: %w", path, err)
+		}
+	}
+	return resp.Meta, nil
+}
 
-	// 设置认证头（如果启用了认证）
-	req.SetBasicAuth(userName, password)
-	req.Header.Set("Content-Type", "application/json")
+// do 发送请求并解析JSON响应，in不为空时作为JSON请求体，非2xx状态码返回*EmqxError
+func (c *EmqxClient) do(ctx context.Context, method, path string, in, out any) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultEmqxTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Addr+"/api/v5"+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	client := &http.Client{}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		fmt.Println(err)
-		return false
+		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		return true
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &EmqxError{StatusCode: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		_ = json.Unmarshal(data, e)
+		return e
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("emqx: decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// setQuery 非空时设置查询参数
+func setQuery(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
 	}
+}
 
-	return false
+// CheckEmqxClientExist 检测Emqx客户端是否存在，请求失败时返回false
+//
+// 需要区分请求失败或批量查询时使用EmqxClient
+func CheckEmqxClientExist(emqxAddr string, userName string, password string, clientID string) bool {
+	ok, _ := NewEmqxClient(emqxAddr, userName, password).ClientExists(context.Background(), clientID)
+	return ok
 }
@@ -0,0 +1,320 @@
+package qmonitor
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEmqx 模拟EMQX v5管理接口
+type fakeEmqx struct {
+	*httptest.Server
+	mu        sync.Mutex
+	clients   map[string]EmqxClientInfo
+	subs      []EmqxSubscription
+	delay     time.Duration
+	published []emqxMessage
+	queries   []string
+}
+
+func newFakeEmqx(t *testing.T) *fakeEmqx {
+	f := &fakeEmqx{clients: map[string]EmqxClientInfo{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v5/clients", f.handleClients)
+	mux.HandleFunc("/api/v5/clients/", f.handleClient)
+	mux.HandleFunc("/api/v5/subscriptions", f.handleSubscriptions)
+	mux.HandleFunc("/api/v5/topics", f.handleTopics)
+	mux.HandleFunc("/api/v5/stats", f.handleStats)
+	mux.HandleFunc("/api/v5/publish", f.handlePublish)
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "key" || pass != "secret" {
+			writeEmqxError(w, http.StatusUnauthorized, "BAD_API_KEY_OR_SECRET", "Check api_key/api_secret")
+			return
+		}
+		f.mu.Lock()
+		f.queries = append(f.queries, r.Method+" "+r.URL.RequestURI())
+		delay := f.delay
+		f.mu.Unlock()
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeEmqx) client() *EmqxClient {
+	return NewEmqxClient(f.URL+"/", "key", "secret")
+}
+
+func (f *fakeEmqx) connect(ids ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range ids {
+		f.clients[id] = EmqxClientInfo{ClientID: id, Username: "dev", Node: "emqx@127.0.0.1", Connected: true,
+			ConnectedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	}
+}
+
+func writeEmqxError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"code": code, "message": message})
+}
+
+// writePage 按page、limit分页输出
+func writePage[T any](w http.ResponseWriter, r *http.Request, list []T) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	start := (page - 1) * limit
+	if start > len(list) {
+		start = len(list)
+	}
+	end := start + limit
+	if end > len(list) {
+		end = len(list)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data": list[start:end],
+		"meta": EmqxPage{Page: page, Limit: limit, Count: len(list), HasNext: end < len(list)},
+	})
+}
+
+func (f *fakeEmqx) handleClients(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	var list []EmqxClientInfo
+	q := r.URL.Query()
+	for _, c := range f.clients {
+		if like := q.Get("like_clientid"); like != "" && !strings.Contains(c.ClientID, like) {
+			continue
+		}
+		if state := q.Get("conn_state"); state != "" && (state == "connected") != c.Connected {
+			continue
+		}
+		list = append(list, c)
+	}
+	f.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].ClientID < list[j].ClientID })
+	writePage(w, r, list)
+}
+
+func (f *fakeEmqx) handleClient(w http.ResponseWriter, r *http.Request) {
+	id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/api/v5/clients/"), "/")
+	id, _ = url.PathUnescape(id)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.clients[id]
+	if !ok {
+		writeEmqxError(w, http.StatusNotFound, "CLIENTID_NOT_FOUND", "Client ID not found")
+		return
+	}
+	switch {
+	case sub == "subscriptions":
+		list := []EmqxSubscription{}
+		for _, s := range f.subs {
+			if s.ClientID == id {
+				list = append(list, s)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	case r.Method == http.MethodDelete:
+		delete(f.clients, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		_ = json.NewEncoder(w).Encode(c)
+	}
+}
+
+func (f *fakeEmqx) handleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	var list []EmqxSubscription
+	for _, s := range f.subs {
+		if topic := r.URL.Query().Get("topic"); topic != "" && s.Topic != topic {
+			continue
+		}
+		list = append(list, s)
+	}
+	f.mu.Unlock()
+	writePage(w, r, list)
+}
+
+func (f *fakeEmqx) handleTopics(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	var list []EmqxTopic
+	for _, s := range f.subs {
+		list = append(list, EmqxTopic{Topic: s.Topic, Node: s.Node})
+	}
+	f.mu.Unlock()
+	writePage(w, r, list)
+}
+
+func (f *fakeEmqx) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("aggregate") != "true" {
+		http.Error(w, "aggregate required", http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_ = json.NewEncoder(w).Encode(map[string]int64{
+		"connections.count":   int64(len(f.clients)),
+		"subscriptions.count": int64(len(f.subs)),
+	})
+}
+
+// emqxMessage 发布接口收到的消息
+type emqxMessage struct {
+	Topic           string `json:"topic"`
+	Payload         string `json:"payload"`
+	PayloadEncoding string `json:"payload_encoding"`
+	QoS             int    `json:"qos"`
+	Retain          bool   `json:"retain"`
+}
+
+func (f *fakeEmqx) handlePublish(w http.ResponseWriter, r *http.Request) {
+	var msg emqxMessage
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&msg) != nil {
+		writeEmqxError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid message")
+		return
+	}
+	if msg.PayloadEncoding == "base64" {
+		data, _ := base64.StdEncoding.DecodeString(msg.Payload)
+		msg.Payload = string(data)
+	}
+	f.mu.Lock()
+	f.published = append(f.published, msg)
+	id := len(f.published)
+	f.mu.Unlock()
+	_ = json.NewEncoder(w).Encode(map[string]string{"id": strconv.Itoa(id)})
+}
+
+func TestEmqxClient(t *testing.T) {
+	f := newFakeEmqx(t)
+	f.connect("dev-1", "dev/2")
+	f.subs = []EmqxSubscription{{ClientID: "dev-1", Topic: "up/1", QoS: 1, Node: "emqx@127.0.0.1"}}
+	c := f.client()
+	ctx := context.Background()
+
+	info, err := c.Client(ctx, "dev/2")
+	if err != nil || info.ClientID != "dev/2" || !info.Connected || info.ConnectedAt.Year() != 2024 {
+		t.Fatalf("client = %+v %v", info, err)
+	}
+	if ok, err := c.ClientExists(ctx, "dev-1"); !ok || err != nil {
+		t.Fatalf("exists = %v %v", ok, err)
+	}
+	if ok, err := c.ClientExists(ctx, "missing"); ok || err != nil {
+		t.Fatalf("missing = %v %v", ok, err)
+	}
+	_, err = c.Client(ctx, "missing")
+	var e *EmqxError
+	if !errors.Is(err, ErrEmqxNotFound) || !errors.As(err, &e) || e.Code != "CLIENTID_NOT_FOUND" {
+		t.Fatalf("err = %v", err)
+	}
+
+	subs, err := c.ClientSubscriptions(ctx, "dev-1")
+	if err != nil || len(subs) != 1 || subs[0].Topic != "up/1" || subs[0].QoS != 1 {
+		t.Fatalf("subs = %+v %v", subs, err)
+	}
+	subs, meta, err := c.Subscriptions(ctx, EmqxSubscriptionFilter{Topic: "up/1"}, 1, 10)
+	if err != nil || len(subs) != 1 || meta.Count != 1 {
+		t.Fatalf("subs = %+v %+v %v", subs, meta, err)
+	}
+	topics, _, err := c.Topics(ctx, "", 0, 0)
+	if err != nil || len(topics) != 1 || topics[0].Topic != "up/1" {
+		t.Fatalf("topics = %+v %v", topics, err)
+	}
+	stats, err := c.Stats(ctx)
+	if err != nil || stats["connections.count"] != 2 || stats["subscriptions.count"] != 1 {
+		t.Fatalf("stats = %v %v", stats, err)
+	}
+
+	id, err := c.Publish(ctx, "cmd/dev-1", []byte("\x00reboot"), 1, true)
+	if err != nil || id != "1" || f.published[0] != (emqxMessage{"cmd/dev-1", "\x00reboot", "base64", 1, true}) {
+		t.Fatalf("publish = %s %+v %v", id, f.published, err)
+	}
+
+	if err = c.Kick(ctx, "dev-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Kick(ctx, "dev-1"); !errors.Is(err, ErrEmqxNotFound) {
+		t.Fatalf("kick again = %v", err)
+	}
+	if CheckEmqxClientExist(f.URL, "key", "secret", "dev-1") || !CheckEmqxClientExist(f.URL, "key", "secret", "dev/2") {
+		t.Fatal("CheckEmqxClientExist")
+	}
+}
+
+func TestEmqxClientPagination(t *testing.T) {
+	f := newFakeEmqx(t)
+	for i := 0; i < 250; i++ {
+		f.connect("dev-" + strconv.Itoa(i))
+	}
+	f.connect("other")
+	c := f.client()
+
+	list, meta, err := c.Clients(context.Background(), EmqxClientFilter{}, 2, 100)
+	if err != nil || len(list) != 100 || !meta.HasNext || meta.Count != 251 {
+		t.Fatalf("page 2 = %d %+v %v", len(list), meta, err)
+	}
+	all, err := c.AllClients(context.Background(), EmqxClientFilter{LikeClientID: "dev-", ConnState: "connected"})
+	if err != nil || len(all) != 250 {
+		t.Fatalf("all = %d %v", len(all), err)
+	}
+	last := f.queries[len(f.queries)-1]
+	if !strings.Contains(last, "like_clientid=dev-") || !strings.Contains(last, "conn_state=connected") || !strings.Contains(last, "page=3") {
+		t.Fatalf("query = %s", last)
+	}
+
+	c.PageLimit = 50
+	if all, err = c.AllClients(context.Background(), EmqxClientFilter{LikeClientID: "dev-"}); err != nil || len(all) != 250 {
+		t.Fatalf("all = %d %v", len(all), err)
+	}
+	if last = f.queries[len(f.queries)-1]; !strings.Contains(last, "limit=50") || !strings.Contains(last, "page=5") {
+		t.Fatalf("query = %s", last)
+	}
+}
+
+func TestEmqxClientErrors(t *testing.T) {
+	f := newFakeEmqx(t)
+	c := f.client()
+	c.Password = "wrong"
+	if _, err := c.ClientExists(context.Background(), "dev-1"); !errors.Is(err, ErrEmqxUnauthorized) {
+		t.Fatalf("err = %v", err)
+	}
+	if CheckEmqxClientExist(f.URL, "key", "wrong", "dev-1") {
+		t.Fatal("unauthorized should be false")
+	}
+
+	c = f.client()
+	c.Timeout = time.Millisecond * 50
+	f.mu.Lock()
+	f.delay = time.Second
+	f.mu.Unlock()
+	start := time.Now()
+	if _, err := c.Stats(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+	if time.Since(start) > time.Millisecond*500 {
+		t.Fatal("timeout not applied")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.client().Stats(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package qmonitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// lifecycle 一组后台协程的启动、停止状态，Stop之后不能再次启动或添加协程
+//
+//	除wait外的方法调用时需持有使用方的锁
+type lifecycle struct {
+	name    string // 使用方名称，用于错误信息
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// start 开始运行，ctx取消或stop时所有协程的ctx取消
+func (l *lifecycle) start(ctx context.Context) error {
+	if err := l.check(); err != nil {
+		return err
+	}
+	if l.cancel != nil {
+		return fmt.Errorf("%s already started", l.name)
+	}
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	return nil
+}
+
+// check 已停止时返回错误
+func (l *lifecycle) check() error {
+	if l.stopped {
+		return fmt.Errorf("%s stopped", l.name)
+	}
+	return nil
+}
+
+// running 是否已启动且未停止
+func (l *lifecycle) running() bool {
+	return l.cancel != nil
+}
+
+// goWith 启动协程，需在running时调用
+func (l *lifecycle) goWith(fn func(ctx context.Context)) {
+	ctx := l.ctx
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		fn(ctx)
+	}()
+}
+
+// stop 标记为已停止并取消所有协程，释放锁后调用wait等待协程退出
+func (l *lifecycle) stop() {
+	if l.cancel != nil {
+		l.cancel()
+	}
+	l.ctx, l.cancel = nil, nil
+	l.stopped = true
+}
+
+// wait 等待所有协程退出，调用时不能持有使用方的锁
+func (l *lifecycle) wait() {
+	l.wg.Wait()
+}
@@ -0,0 +1,396 @@
+package qmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCollectInterval 默认的采集间隔
+const DefaultCollectInterval = time.Second * 10
+
+// HostMetrics 主机指标快照
+type HostMetrics struct {
+	Time       time.Time `json:"time"`
+	CPUPercent float64   `json:"cpuPercent"` // 与上次采集之间的CPU占用率，首次采集为开机以来的平均值
+	PerCPU     []float64 `json:"perCpu"`     // 各核的CPU占用率
+	Load1      float64   `json:"load1"`
+	Load5      float64   `json:"load5"`
+	Load15     float64   `json:"load15"`
+
+	MemTotal     uint64  `json:"memTotal"` // 字节
+	MemAvailable uint64  `json:"memAvailable"`
+	MemUsed      uint64  `json:"memUsed"`
+	MemPercent   float64 `json:"memPercent"`
+	SwapTotal    uint64  `json:"swapTotal"`
+	SwapUsed     uint64  `json:"swapUsed"`
+
+	Networks []NetMetrics  `json:"networks"`
+	Disks    []DiskMetrics `json:"disks"`
+}
+
+// NetMetrics 网卡指标
+type NetMetrics struct {
+	Name      string  `json:"name"`
+	RxBytes   uint64  `json:"rxBytes"` // 累计接收字节数
+	TxBytes   uint64  `json:"txBytes"`
+	RxPackets uint64  `json:"rxPackets"`
+	TxPackets uint64  `json:"txPackets"`
+	RxErrors  uint64  `json:"rxErrors"`
+	TxErrors  uint64  `json:"txErrors"`
+	RxRate    float64 `json:"rxRate"` // 与上次采集之间的接收速率，字节/秒
+	TxRate    float64 `json:"txRate"`
+}
+
+// DiskMetrics 磁盘分区指标
+type DiskMetrics struct {
+	Path        string  `json:"path"`
+	Total       uint64  `json:"total"`       // 字节
+	Free        uint64  `json:"free"`        // 非特权用户可用的字节数
+	Used        uint64  `json:"used"`        // 不含保留给root的空闲块，与df一致
+	UsedPercent float64 `json:"usedPercent"` // Used / (Used + Free)，与df一致
+}
+
+// MetricsSink 指标输出
+type MetricsSink interface {
+	Write(ctx context.Context, m HostMetrics) error
+}
+
+// MetricsSinkFunc 以方法实现的指标输出
+type MetricsSinkFunc func(ctx context.Context, m HostMetrics) error
+
+func (f MetricsSinkFunc) Write(ctx context.Context, m HostMetrics) error {
+	return f(ctx, m)
+}
+
+// NewJSONSink 创建按行输出JSON的指标输出
+//
+//	@param w
+//	@return MetricsSink
+func NewJSONSink(w io.Writer) MetricsSink {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return MetricsSinkFunc(func(ctx context.Context, m HostMetrics) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return enc.Encode(m)
+	})
+}
+
+// Collector 主机指标采集器，从proc文件系统和statfs读取
+type Collector struct {
+	Root      string        // proc文件系统的挂载点，默认为/proc
+	Interval  time.Duration // 采集间隔，0使用DefaultCollectInterval
+	DiskPaths []string      // 需要统计的磁盘挂载点，默认为根目录
+
+	mu    sync.Mutex
+	sinks []MetricsSink
+	prev  *sample
+	now   func() time.Time
+	// statfs 获取挂载点的总字节数、空闲字节数和非特权用户可用的字节数
+	statfs func(path string) (total, free, avail uint64, err error)
+}
+
+// sample 计算速率所需的上次采集的累计值
+type sample struct {
+	time time.Time
+	cpu  []cpuTimes // 第0项为总计
+	net  map[string]NetMetrics
+}
+
+type cpuTimes struct {
+	total, idle uint64
+}
+
+// NewCollector 创建主机指标采集器
+//
+//	@param root proc文件系统的挂载点，为空时使用/proc
+//	@return *Collector
+func NewCollector(root string) *Collector {
+	if root == "" {
+		root = "/proc"
+	}
+	return &Collector{Root: root, now: time.Now, statfs: statfs}
+}
+
+// AddSink 添加指标输出
+//
+//	@param sink
+func (c *Collector) AddSink(sink MetricsSink) {
+	c.mu.Lock()
+	c.sinks = append(c.sinks, sink)
+	c.mu.Unlock()
+}
+
+// Collect 采集一次指标，速率按与上次采集之间的差值计算
+//
+//	@return HostMetrics
+//	@return error
+func (c *Collector) Collect() (HostMetrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cur := &sample{time: c.now()}
+	m := HostMetrics{Time: cur.time}
+	var err error
+	if cur.cpu, err = c.readCPU(); err != nil {
+		return m, err
+	}
+	if err = c.readMem(&m); err != nil {
+		return m, err
+	}
+	if err = c.readLoad(&m); err != nil {
+		return m, err
+	}
+	if cur.net, err = c.readNet(); err != nil {
+		return m, err
+	}
+
+	var prevCPU []cpuTimes
+	var elapsed float64
+	if c.prev != nil {
+		prevCPU = c.prev.cpu
+		elapsed = cur.time.Sub(c.prev.time).Seconds()
+	}
+	for i, t := range cur.cpu {
+		var p cpuTimes
+		if i < len(prevCPU) {
+			p = prevCPU[i]
+		}
+		percent := cpuPercent(p, t)
+		if i == 0 {
+			m.CPUPercent = percent
+		} else {
+			m.PerCPU = append(m.PerCPU, percent)
+		}
+	}
+
+	names := make([]string, 0, len(cur.net))
+	for name := range cur.net {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n := cur.net[name]
+		if c.prev != nil && elapsed > 0 {
+			if p, ok := c.prev.net[name]; ok {
+				n.RxRate = rate(p.RxBytes, n.RxBytes, elapsed)
+				n.TxRate = rate(p.TxBytes, n.TxBytes, elapsed)
+			}
+		}
+		m.Networks = append(m.Networks, n)
+	}
+
+	paths := c.DiskPaths
+	if len(paths) == 0 {
+		paths = []string{string(filepath.Separator)}
+	}
+	for _, path := range paths {
+		total, free, avail, err := c.statfs(path)
+		if err != nil {
+			return m, fmt.Errorf("statfs %s: %w", path, err)
+		}
+		// 与df一致，保留给root的块不计入已用，使用率按非特权用户可用的容量计算
+		d := DiskMetrics{Path: path, Total: total, Free: avail}
+		if total > free {
+			d.Used = total - free
+		}
+		if d.Used+avail > 0 {
+			d.UsedPercent = float64(d.Used) / float64(d.Used+avail) * 100
+		}
+		m.Disks = append(m.Disks, d)
+	}
+
+	c.prev = cur
+	return m, nil
+}
+
+// Run 按间隔采集并写入所有输出，阻塞直到ctx取消
+//
+//	@param ctx
+func (c *Collector) Run(ctx context.Context) {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultCollectInterval
+	}
+	// 先采集一次作为计算速率的基准
+	_, _ = c.Collect()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m, err := c.Collect()
+			if err != nil {
+				log.Println(fmt.Sprintf("[Collector] Collect error, %s", err))
+				continue
+			}
+			c.mu.Lock()
+			sinks := append([]MetricsSink(nil), c.sinks...)
+			c.mu.Unlock()
+			for _, sink := range sinks {
+				if err = sink.Write(ctx, m); err != nil {
+					log.Println(fmt.Sprintf("[Collector] Sink error, %s", err))
+				}
+			}
+		}
+	}
+}
+
+// readCPU 读取 /proc/stat 中的cpu行
+func (c *Collector) readCPU() ([]cpuTimes, error) {
+	data, err := os.ReadFile(filepath.Join(c.Root, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	var list []cpuTimes
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		// user nice system idle iowait irq softirq steal，guest已包含在user中
+		var t cpuTimes
+		for i, f := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			v, _ := strconv.ParseUint(f, 10, 64)
+			t.total += v
+			if i == 3 || i == 4 {
+				t.idle += v
+			}
+		}
+		list = append(list, t)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("cpu not found in %s", filepath.Join(c.Root, "stat"))
+	}
+	return list, nil
+}
+
+// readMem 读取 /proc/meminfo
+func (c *Collector) readMem(m *HostMetrics) error {
+	data, err := os.ReadFile(filepath.Join(c.Root, "meminfo"))
+	if err != nil {
+		return err
+	}
+	values := map[string]uint64{}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		v, _ := strconv.ParseUint(fields[0], 10, 64)
+		if len(fields) > 1 && fields[1] == "kB" {
+			v *= 1024
+		}
+		values[key] = v
+	}
+	m.MemTotal = values["MemTotal"]
+	available, ok := values["MemAvailable"]
+	if !ok {
+		// 3.14之前的内核没有MemAvailable
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	m.MemAvailable = available
+	if m.MemTotal > available {
+		m.MemUsed = m.MemTotal - available
+	}
+	if m.MemTotal > 0 {
+		m.MemPercent = float64(m.MemUsed) / float64(m.MemTotal) * 100
+	}
+	m.SwapTotal = values["SwapTotal"]
+	if m.SwapTotal > values["SwapFree"] {
+		m.SwapUsed = m.SwapTotal - values["SwapFree"]
+	}
+	return nil
+}
+
+// readLoad 读取 /proc/loadavg
+func (c *Collector) readLoad(m *HostMetrics) error {
+	data, err := os.ReadFile(filepath.Join(c.Root, "loadavg"))
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return fmt.Errorf("invalid loadavg")
+	}
+	m.Load1, _ = strconv.ParseFloat(fields[0], 64)
+	m.Load5, _ = strconv.ParseFloat(fields[1], 64)
+	m.Load15, _ = strconv.ParseFloat(fields[2], 64)
+	return nil
+}
+
+// readNet 读取 /proc/net/dev
+func (c *Collector) readNet() (map[string]NetMetrics, error) {
+	data, err := os.ReadFile(filepath.Join(c.Root, "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]NetMetrics{}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// 接收: bytes packets errs drop fifo frame compressed multicast
+		// 发送: bytes packets errs drop fifo colls carrier compressed
+		fields := strings.Fields(value)
+		if len(fields) < 16 {
+			continue
+		}
+		num := func(i int) uint64 {
+			v, _ := strconv.ParseUint(fields[i], 10, 64)
+			return v
+		}
+		name = strings.TrimSpace(name)
+		result[name] = NetMetrics{
+			Name:      name,
+			RxBytes:   num(0),
+			RxPackets: num(1),
+			RxErrors:  num(2),
+			TxBytes:   num(8),
+			TxPackets: num(9),
+			TxErrors:  num(10),
+		}
+	}
+	return result, nil
+}
+
+// cpuPercent 计算两次采样之间的CPU占用率
+func cpuPercent(prev, cur cpuTimes) float64 {
+	if cur.total <= prev.total {
+		return 0
+	}
+	total := float64(cur.total - prev.total)
+	idle := float64(0)
+	if cur.idle > prev.idle {
+		idle = float64(cur.idle - prev.idle)
+	}
+	return (total - idle) / total * 100
+}
+
+// rate 计算每秒增量，计数器回绕或重置时返回0
+func rate(prev, cur uint64, seconds float64) float64 {
+	if cur < prev || seconds <= 0 {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
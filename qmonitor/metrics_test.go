@@ -0,0 +1,165 @@
+package qmonitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+const fixtureMeminfo = `MemTotal:        8000000 kB
+MemFree:          500000 kB
+MemAvailable:    6000000 kB
+Buffers:          100000 kB
+Cached:          2000000 kB
+SwapTotal:       1000000 kB
+SwapFree:         750000 kB
+`
+
+const fixtureNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: %d     10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0: %d   2000    1    0    0     0          0         0  %d    1500    2    0    0     0       0          0
+`
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+// fixtureCollector 使用伪造/proc的采集器，时间和磁盘信息由测试控制
+func fixtureCollector(t *testing.T) (*Collector, *fakeProc, *time.Time) {
+	fp := newFakeProc(t, 1000)
+	fp.write("meminfo", fixtureMeminfo)
+	fp.write("loadavg", "0.50 1.25 2.00 2/345 6789\n")
+	now := time.Unix(fakeBootTime+1000, 0)
+	c := NewCollector(fp.root)
+	c.now = func() time.Time { return now }
+	c.DiskPaths = []string{"/data"}
+	c.statfs = func(path string) (uint64, uint64, uint64, error) {
+		// 50字节保留给root
+		return 1000, 300, 250, nil
+	}
+	return c, fp, &now
+}
+
+func writeCPU(fp *fakeProc, total, cpu0, cpu1 string) {
+	fp.write("stat", "cpu  "+total+"\ncpu0 "+cpu0+"\ncpu1 "+cpu1+"\nintr 12345\nbtime 1700000000\n")
+}
+
+func TestCollector(t *testing.T) {
+	c, fp, now := fixtureCollector(t)
+	// user nice system idle iowait irq softirq steal guest guest_nice
+	writeCPU(fp, "100 0 100 700 100 0 0 0 50 0", "50 0 50 350 50 0 0 0 0 0", "50 0 50 350 50 0 0 0 0 0")
+	fp.write("net/dev", fmt.Sprintf(fixtureNetDev, 1000, 10000, 5000))
+
+	m, err := c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 首次采集为开机以来的平均值：(1000-800)/1000
+	if !approx(m.CPUPercent, 20) || len(m.PerCPU) != 2 || !approx(m.PerCPU[0], 20) {
+		t.Fatalf("cpu = %v %v", m.CPUPercent, m.PerCPU)
+	}
+	if m.MemTotal != 8000000*1024 || m.MemAvailable != 6000000*1024 || m.MemUsed != 2000000*1024 || !approx(m.MemPercent, 25) {
+		t.Fatalf("mem = %+v", m)
+	}
+	if m.SwapTotal != 1000000*1024 || m.SwapUsed != 250000*1024 {
+		t.Fatalf("swap = %d %d", m.SwapTotal, m.SwapUsed)
+	}
+	if m.Load1 != 0.5 || m.Load5 != 1.25 || m.Load15 != 2 {
+		t.Fatalf("load = %v %v %v", m.Load1, m.Load5, m.Load15)
+	}
+	if len(m.Networks) != 2 || m.Networks[0].Name != "eth0" || m.Networks[0].RxBytes != 10000 || m.Networks[0].TxBytes != 5000 ||
+		m.Networks[0].RxErrors != 1 || m.Networks[0].TxErrors != 2 || m.Networks[0].RxRate != 0 {
+		t.Fatalf("net = %+v", m.Networks)
+	}
+	if len(m.Disks) != 1 || m.Disks[0].Path != "/data" || m.Disks[0].Used != 700 || m.Disks[0].Free != 250 || !approx(m.Disks[0].UsedPercent, 700.0/950*100) {
+		t.Fatalf("disks = %+v", m.Disks)
+	}
+
+	// 10秒后：cpu0满载，cpu1空闲；eth0接收100KB、发送20KB；lo计数器重置
+	*now = now.Add(time.Second * 10)
+	writeCPU(fp, "600 0 100 1200 100 0 0 0 50 0", "550 0 50 350 50 0 0 0 0 0", "50 0 50 850 50 0 0 0 0 0")
+	fp.write("net/dev", fmt.Sprintf(fixtureNetDev, 10, 10000+100000, 5000+20000))
+
+	m, err = c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approx(m.CPUPercent, 50) || !approx(m.PerCPU[0], 100) || !approx(m.PerCPU[1], 0) {
+		t.Fatalf("cpu = %v %v", m.CPUPercent, m.PerCPU)
+	}
+	if eth := m.Networks[0]; !approx(eth.RxRate, 10000) || !approx(eth.TxRate, 2000) {
+		t.Fatalf("eth0 = %+v", eth)
+	}
+	if lo := m.Networks[1]; lo.RxRate != 0 {
+		t.Fatalf("lo = %+v", lo)
+	}
+}
+
+func TestCollectorMissingFiles(t *testing.T) {
+	c, _, _ := fixtureCollector(t)
+	// 伪造的/proc中没有net/dev
+	if _, err := c.Collect(); err == nil {
+		t.Fatal("missing net/dev should fail")
+	}
+}
+
+func TestCollectorRun(t *testing.T) {
+	c, fp, now := fixtureCollector(t)
+	writeCPU(fp, "100 0 100 700 100 0 0 0 0 0", "0 0 0 0 0 0 0 0 0 0", "0 0 0 0 0 0 0 0 0 0")
+	fp.write("net/dev", fmt.Sprintf(fixtureNetDev, 0, 0, 0))
+	c.Interval = time.Millisecond * 10
+	c.now = func() time.Time {
+		*now = now.Add(time.Second)
+		return *now
+	}
+
+	var buf bytes.Buffer
+	c.AddSink(NewJSONSink(&buf))
+	got := make(chan HostMetrics, 10)
+	c.AddSink(MetricsSinkFunc(func(ctx context.Context, m HostMetrics) error {
+		got <- m
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	select {
+	case m := <-got:
+		if m.Load15 != 2 {
+			t.Fatalf("metrics = %+v", m)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("timeout")
+	}
+	cancel()
+	<-done
+
+	var m HostMetrics
+	line, _ := bytes.NewBuffer(buf.Bytes()).ReadBytes('\n')
+	if err := json.Unmarshal(line, &m); err != nil || m.MemTotal != 8000000*1024 {
+		t.Fatalf("json = %s %v", line, err)
+	}
+}
+
+func TestCollectorHost(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux only")
+	}
+	m, err := NewCollector("").Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MemTotal == 0 || len(m.PerCPU) == 0 || len(m.Disks) != 1 || m.Disks[0].Total == 0 {
+		t.Fatalf("metrics = %+v", m)
+	}
+}
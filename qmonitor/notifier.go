@@ -0,0 +1,103 @@
+package qmonitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultNotifyTimeout 默认的通知请求超时时间
+const DefaultNotifyTimeout = time.Second * 10
+
+// WebhookNotifier 以JSON格式POST告警到指定地址
+type WebhookNotifier struct {
+	URL     string
+	Header  map[string]string
+	Timeout time.Duration // 0使用DefaultNotifyTimeout
+	Client  *http.Client  // 为空时使用http.DefaultClient
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, durationOr(n.Timeout, DefaultNotifyTimeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.Header {
+		req.Header.Set(k, v)
+	}
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: status %d", n.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// FileNotifier 按行追加JSON格式的告警到日志文件
+type FileNotifier struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (n *FileNotifier) Notify(ctx context.Context, alert Alert) error {
+	line, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err = os.MkdirAll(filepath.Dir(n.Path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(n.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// EmqxNotifier 通过EMQX的HTTP发布接口将JSON格式的告警发布到MQTT主题
+type EmqxNotifier struct {
+	Client *EmqxClient
+	Topic  string // 主题，{rule}替换为规则名称，如 alerts/{rule}
+	QoS    int
+	Retain bool
+}
+
+func (n *EmqxNotifier) Notify(ctx context.Context, alert Alert) error {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	topic := strings.ReplaceAll(n.Topic, "{rule}", alert.Rule)
+	_, err = n.Client.Publish(ctx, topic, payload, n.QoS, n.Retain)
+	return err
+}
@@ -0,0 +1,271 @@
+package qmonitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultPresenceInterval 默认的在线状态刷新间隔
+const DefaultPresenceInterval = time.Second * 30
+
+// PresenceEvent 客户端上下线事件
+type PresenceEvent struct {
+	ClientID string
+	Online   bool
+	Time     time.Time // 上线时为连接时间，下线时为发现下线的时间
+}
+
+// Presence 批量跟踪客户端在线状态，定时从EMQX接口拉取所有已连接的客户端
+type Presence struct {
+	Client   *EmqxClient
+	Interval time.Duration    // 刷新间隔，0使用DefaultPresenceInterval
+	Filter   EmqxClientFilter // 拉取客户端的过滤条件，如按客户端ID前缀缩小范围，ConnState固定为connected
+
+	mu       sync.Mutex
+	all      bool                // 跟踪所有客户端，此时忽略watched
+	watched  map[string]struct{} // 跟踪的客户端，为空时不跟踪任何客户端
+	online   map[string]time.Time
+	lastSync time.Time
+	lastErr  error
+	subs     map[int]func(PresenceEvent)
+	nextSub  int
+}
+
+// NewPresence 创建在线状态跟踪
+//
+//	@param client
+//	@param clientIDs 需要跟踪的客户端ID，为空时跟踪所有客户端
+//	@return *Presence
+func NewPresence(client *EmqxClient, clientIDs ...string) *Presence {
+	p := &Presence{
+		Client:  client,
+		all:     len(clientIDs) == 0,
+		watched: map[string]struct{}{},
+		online:  map[string]time.Time{},
+		subs:    map[int]func(PresenceEvent){},
+	}
+	p.Watch(clientIDs...)
+	return p
+}
+
+// WatchAll 跟踪所有客户端，之前添加的客户端ID不再单独记录，下次刷新生效
+func (p *Presence) WatchAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.all = true
+	p.watched = map[string]struct{}{}
+}
+
+// Watch 添加跟踪的客户端，下次刷新生效；跟踪所有客户端时改为只跟踪指定的客户端
+//
+//	@param clientIDs
+func (p *Presence) Watch(clientIDs ...string) {
+	if len(clientIDs) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.all = false
+	for _, id := range clientIDs {
+		p.watched[id] = struct{}{}
+	}
+}
+
+// Unwatch 取消跟踪客户端，不产生下线事件；全部取消后不跟踪任何客户端，需要时调用WatchAll
+//
+//	跟踪所有客户端时无效，客户端在下次刷新时重新出现
+//	@param clientIDs
+func (p *Presence) Unwatch(clientIDs ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, id := range clientIDs {
+		delete(p.watched, id)
+		delete(p.online, id)
+	}
+}
+
+// Subscribe 订阅上下线事件，回调在刷新的协程中按顺序执行
+//
+//	@param fn
+//	@return func() 取消订阅
+func (p *Presence) Subscribe(fn func(PresenceEvent)) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := p.nextSub
+	p.nextSub++
+	p.subs[id] = fn
+	return func() {
+		p.mu.Lock()
+		delete(p.subs, id)
+		p.mu.Unlock()
+	}
+}
+
+// Refresh 拉取一次已连接的客户端并更新在线状态
+//
+// 首次刷新只产生上线事件；请求失败时保留原有状态
+//
+//	@param ctx
+//	@return error
+func (p *Presence) Refresh(ctx context.Context) error {
+	filter := p.Filter
+	filter.ConnState = "connected"
+	list, err := p.Client.AllClients(ctx, filter)
+	now := time.Now()
+
+	p.mu.Lock()
+	p.lastErr = err
+	if err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	current := map[string]time.Time{}
+	for _, c := range list {
+		if !p.isWatched(c.ClientID) {
+			continue
+		}
+		since := c.ConnectedAt
+		if since.IsZero() {
+			since = now
+		}
+		current[c.ClientID] = since
+	}
+
+	var events []PresenceEvent
+	for id, since := range current {
+		if _, ok := p.online[id]; !ok {
+			events = append(events, PresenceEvent{ClientID: id, Online: true, Time: since})
+		}
+	}
+	for id := range p.online {
+		if _, ok := current[id]; !ok {
+			events = append(events, PresenceEvent{ClientID: id, Online: false, Time: now})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ClientID < events[j].ClientID })
+	p.online = current
+	p.lastSync = now
+	subs := make([]func(PresenceEvent), 0, len(p.subs))
+	ids := make([]int, 0, len(p.subs))
+	for id := range p.subs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		subs = append(subs, p.subs[id])
+	}
+	p.mu.Unlock()
+
+	for _, e := range events {
+		for _, fn := range subs {
+			fn(e)
+		}
+	}
+	return nil
+}
+
+// Run 按间隔刷新，阻塞直到ctx取消
+//
+//	@param ctx
+func (p *Presence) Run(ctx context.Context) {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultPresenceInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Println(fmt.Sprintf("[Presence] Refresh error, %s", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsOnline 客户端是否在线
+//
+//	@param clientID
+//	@return bool
+func (p *Presence) IsOnline(clientID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.online[clientID]
+	return ok
+}
+
+// Since 客户端的连接时间
+//
+//	@param clientID
+//	@return time.Time
+//	@return bool 是否在线
+func (p *Presence) Since(clientID string) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	since, ok := p.online[clientID]
+	return since, ok
+}
+
+// Online 所有在线的客户端ID，已排序
+//
+//	@return []string
+func (p *Presence) Online() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	list := make([]string, 0, len(p.online))
+	for id := range p.online {
+		list = append(list, id)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Offline 跟踪的客户端中不在线的ID，已排序，跟踪所有客户端时返回空
+//
+//	@return []string
+func (p *Presence) Offline() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var list []string
+	for id := range p.watched {
+		if _, ok := p.online[id]; !ok {
+			list = append(list, id)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// LastSync 最后一次成功刷新的时间和最后一次刷新的错误
+//
+//	@return time.Time 从未成功刷新时为零值
+//	@return error
+func (p *Presence) LastSync() (time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastSync, p.lastErr
+}
+
+// Synced 是否已成功刷新过，未刷新前所有客户端均视为不在线
+//
+//	@return bool
+func (p *Presence) Synced() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.lastSync.IsZero()
+}
+
+func (p *Presence) isWatched(clientID string) bool {
+	if p.all {
+		return true
+	}
+	_, ok := p.watched[clientID]
+	return ok
+}
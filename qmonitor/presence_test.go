@@ -0,0 +1,179 @@
+package qmonitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func (f *fakeEmqx) disconnect(ids ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range ids {
+		delete(f.clients, id)
+	}
+}
+
+// eventLog 记录收到的事件，格式为 +id 或 -id
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(e PresenceEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sign := "-"
+	if e.Online {
+		sign = "+"
+	}
+	l.events = append(l.events, sign+e.ClientID)
+}
+
+func (l *eventLog) take() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := strings.Join(l.events, " ")
+	l.events = nil
+	return s
+}
+
+func TestPresence(t *testing.T) {
+	f := newFakeEmqx(t)
+	var ids []string
+	for i := 0; i < 2000; i++ {
+		ids = append(ids, fmt.Sprintf("dev-%04d", i))
+	}
+	f.connect(ids[:1500]...)
+	f.connect("other")
+
+	p := NewPresence(f.client(), ids...)
+	var l eventLog
+	p.Subscribe(l.add)
+	if p.Synced() || p.IsOnline("dev-0000") {
+		t.Fatal("should not be synced")
+	}
+	if err := p.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 2000个ID只需分页请求，不逐个查询
+	if len(f.queries) != 16 {
+		t.Fatalf("queries = %d", len(f.queries))
+	}
+	if !p.Synced() || len(p.Online()) != 1500 || len(p.Offline()) != 500 || p.IsOnline("other") {
+		t.Fatalf("online = %d offline = %d", len(p.Online()), len(p.Offline()))
+	}
+	if since, ok := p.Since("dev-0001"); !ok || since.Year() != 2024 {
+		t.Fatalf("since = %v %v", since, ok)
+	}
+	if events := strings.Fields(l.take()); len(events) != 1500 || events[0] != "+dev-0000" {
+		t.Fatalf("events = %d", len(events))
+	}
+
+	f.disconnect("dev-0001", "dev-0002")
+	f.connect("dev-1999")
+	if err := p.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if events := l.take(); events != "-dev-0001 -dev-0002 +dev-1999" {
+		t.Fatalf("events = %s", events)
+	}
+	if p.IsOnline("dev-0001") || !p.IsOnline("dev-1999") {
+		t.Fatal("state not updated")
+	}
+
+	// 请求失败时保留原有状态
+	c := f.client()
+	c.Password = "wrong"
+	p.Client = c
+	if err := p.Refresh(context.Background()); err == nil {
+		t.Fatal("refresh should fail")
+	}
+	if last, err := p.LastSync(); err == nil || last.IsZero() || !p.IsOnline("dev-0000") || l.take() != "" {
+		t.Fatalf("last = %v %v", last, err)
+	}
+}
+
+func TestPresenceWatchAll(t *testing.T) {
+	f := newFakeEmqx(t)
+	f.connect("a", "b")
+	p := NewPresence(f.client())
+	var l eventLog
+	cancel := p.Subscribe(l.add)
+	if err := p.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(p.Online(), ",") != "a,b" || len(p.Offline()) != 0 || l.take() != "+a +b" {
+		t.Fatalf("online = %v", p.Online())
+	}
+
+	// 取消订阅后不再收到事件，取消跟踪不产生下线事件
+	cancel()
+	f.connect("c")
+	p.Unwatch("a")
+	if err := p.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if l.take() != "" || !p.IsOnline("c") {
+		t.Fatal("unsubscribed")
+	}
+}
+
+func TestPresenceUnwatchAll(t *testing.T) {
+	f := newFakeEmqx(t)
+	f.connect("a", "b")
+	p := NewPresence(f.client(), "a")
+	if err := p.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(p.Online(), ",") != "a" {
+		t.Fatalf("online = %v", p.Online())
+	}
+	// 取消最后一个客户端后不跟踪任何客户端
+	p.Unwatch("a")
+	if err := p.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Online()) != 0 || len(p.Offline()) != 0 {
+		t.Fatalf("online after unwatch = %v", p.Online())
+	}
+	p.WatchAll()
+	if err := p.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(p.Online(), ",") != "a,b" {
+		t.Fatalf("online after watch all = %v", p.Online())
+	}
+}
+
+func TestPresenceRun(t *testing.T) {
+	f := newFakeEmqx(t)
+	p := NewPresence(f.client(), "dev-1")
+	p.Interval = time.Millisecond * 10
+	events := make(chan PresenceEvent, 10)
+	p.Subscribe(func(e PresenceEvent) { events <- e })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	f.connect("dev-1")
+	for _, want := range []bool{true, false} {
+		select {
+		case e := <-events:
+			if e.ClientID != "dev-1" || e.Online != want {
+				t.Fatalf("event = %+v", e)
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatal("timeout")
+		}
+		f.disconnect("dev-1")
+	}
+	cancel()
+	<-done
+}
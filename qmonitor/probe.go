@@ -0,0 +1,399 @@
+package qmonitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProbeInterval 默认的探测间隔
+	DefaultProbeInterval = time.Second * 10
+	// DefaultProbeTimeout 默认的单次探测超时
+	DefaultProbeTimeout = time.Second * 5
+	// DefaultUnhealthyThreshold 默认连续失败多少次后判定为不健康
+	DefaultUnhealthyThreshold = 3
+)
+
+// Probe 探测接口，返回nil表示健康
+type Probe interface {
+	Probe(ctx context.Context) error
+}
+
+// ProbeFunc 以方法实现的探测
+type ProbeFunc func(ctx context.Context) error
+
+func (f ProbeFunc) Probe(ctx context.Context) error {
+	return f(ctx)
+}
+
+// HTTPProbe HTTP探测，检查状态码和响应内容
+type HTTPProbe struct {
+	URL          string
+	Method       string            // 为空时使用GET
+	Header       map[string]string // 请求头
+	ExpectStatus []int             // 期望的状态码，为空时接受2xx和3xx
+	BodyContains string            // 响应内容需包含的字符串，为空不检查
+	BodyMatch    *regexp.Regexp    // 响应内容需匹配的正则，为空不检查
+	Client       *http.Client      // 为空时使用http.DefaultClient
+}
+
+func (p *HTTPProbe) Probe(ctx context.Context) error {
+	method := p.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.URL, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range p.Header {
+		req.Header.Set(k, v)
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// 读完剩余内容(最多64KB)后关闭，连接才能被复用
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		_ = resp.Body.Close()
+	}()
+
+	if !p.statusOK(resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if p.BodyContains == "" && p.BodyMatch == nil {
+		return nil
+	}
+	// 最多读取1MB
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if p.BodyContains != "" && !bytes.Contains(body, []byte(p.BodyContains)) {
+		return fmt.Errorf("body does not contain %q", p.BodyContains)
+	}
+	if p.BodyMatch != nil && !p.BodyMatch.Match(body) {
+		return fmt.Errorf("body does not match %s", p.BodyMatch)
+	}
+	return nil
+}
+
+func (p *HTTPProbe) statusOK(code int) bool {
+	if len(p.ExpectStatus) == 0 {
+		return code >= 200 && code < 400
+	}
+	for _, c := range p.ExpectStatus {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// TCPProbe TCP连接探测，能建立连接即为健康
+type TCPProbe struct {
+	Addr string // 如127.0.0.1:3306
+}
+
+func (p *TCPProbe) Probe(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.Addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// CommandProbe 命令探测，退出码为0即为健康
+type CommandProbe struct {
+	Path           string
+	Args           []string
+	Env            map[string]string // 追加的环境变量
+	Dir            string
+	OutputContains string // 输出需包含的字符串，为空不检查
+}
+
+func (p *CommandProbe) Probe(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, p.Path, p.Args...)
+	cmd.Dir = p.Dir
+	if len(p.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range p.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, truncate(msg, 200))
+		}
+		return err
+	}
+	if p.OutputContains != "" && !strings.Contains(string(out), p.OutputContains) {
+		return fmt.Errorf("output does not contain %q", p.OutputContains)
+	}
+	return nil
+}
+
+// ProbeState 探测目标的健康状态
+type ProbeState int
+
+const (
+	ProbeUnknown   ProbeState = iota // 未达到判定阈值
+	ProbeHealthy                     // 健康
+	ProbeUnhealthy                   // 不健康
+)
+
+func (s ProbeState) String() string {
+	switch s {
+	case ProbeHealthy:
+		return "healthy"
+	case ProbeUnhealthy:
+		return "unhealthy"
+	default:
+		return "unknown"
+	}
+}
+
+// ProbeTarget 探测目标
+type ProbeTarget struct {
+	Name               string        // 名称，唯一
+	Probe              Probe         // 探测方式
+	Interval           time.Duration // 探测间隔，0使用DefaultProbeInterval
+	Timeout            time.Duration // 单次探测超时，0使用DefaultProbeTimeout
+	HealthyThreshold   int           // 连续成功多少次后判定为健康，0为1
+	UnhealthyThreshold int           // 连续失败多少次后判定为不健康，0使用DefaultUnhealthyThreshold
+}
+
+// ProbeStatus 探测目标的状态
+type ProbeStatus struct {
+	Name      string
+	State     ProbeState
+	Since     time.Time     // 进入当前状态的时间
+	LastCheck time.Time     // 最近一次探测时间
+	Latency   time.Duration // 最近一次探测耗时
+	LastError string        // 最近一次探测的错误，成功时为空
+	Successes int           // 连续成功次数
+	Failures  int           // 连续失败次数
+}
+
+// Prober 按间隔探测一组目标，连续成功或失败达到阈值时切换状态，避免抖动
+type Prober struct {
+	OnChange func(status ProbeStatus, previous ProbeState) // 状态切换时回调，在探测协程中执行
+
+	mu      sync.Mutex
+	targets []ProbeTarget
+	states  map[string]*probeState
+	life    lifecycle
+}
+
+type probeState struct {
+	target ProbeTarget
+	mu     sync.Mutex
+	status ProbeStatus
+}
+
+// NewProber 创建探测器
+//
+//	@param targets 探测目标
+//	@return *Prober
+func NewProber(targets ...ProbeTarget) *Prober {
+	return &Prober{targets: targets, states: map[string]*probeState{}, life: lifecycle{name: "prober"}}
+}
+
+// Add 添加探测目标，已启动时立即开始探测
+//
+//	@param target
+//	@return error 名称为空、重复或已调用Stop时返回错误
+func (p *Prober) Add(target ProbeTarget) error {
+	if target.Name == "" || target.Probe == nil {
+		return fmt.Errorf("probe name and probe are required")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.life.check(); err != nil {
+		return err
+	}
+	for _, t := range p.targets {
+		if t.Name == target.Name {
+			return fmt.Errorf("probe %s already exists", target.Name)
+		}
+	}
+	p.targets = append(p.targets, target)
+	if p.life.running() {
+		p.startLocked(target)
+	}
+	return nil
+}
+
+// Start 开始探测所有目标，ctx取消或调用Stop时停止
+//
+//	@param ctx
+//	@return error 已启动或已调用Stop时返回错误
+func (p *Prober) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.life.check(); err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for _, t := range p.targets {
+		if t.Name == "" || t.Probe == nil {
+			return fmt.Errorf("probe name and probe are required")
+		}
+		if names[t.Name] {
+			return fmt.Errorf("probe %s already exists", t.Name)
+		}
+		names[t.Name] = true
+	}
+	if err := p.life.start(ctx); err != nil {
+		return err
+	}
+	for _, t := range p.targets {
+		p.startLocked(t)
+	}
+	return nil
+}
+
+// Stop 停止探测，阻塞直到正在进行的探测结束，之后不能再次启动或添加目标
+func (p *Prober) Stop() {
+	p.mu.Lock()
+	p.life.stop()
+	p.mu.Unlock()
+	p.life.wait()
+}
+
+// Status 获取所有目标的状态，按名称排序
+//
+//	@return []ProbeStatus
+func (p *Prober) Status() []ProbeStatus {
+	p.mu.Lock()
+	list := make([]ProbeStatus, 0, len(p.states))
+	for _, st := range p.states {
+		st.mu.Lock()
+		list = append(list, st.status)
+		st.mu.Unlock()
+	}
+	p.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func (p *Prober) startLocked(target ProbeTarget) {
+	st := &probeState{target: target, status: ProbeStatus{Name: target.Name, Since: time.Now()}}
+	p.states[target.Name] = st
+	p.life.goWith(func(ctx context.Context) {
+		p.run(ctx, st)
+	})
+}
+
+// run 立即探测一次，之后按间隔探测，直到ctx取消
+func (p *Prober) run(ctx context.Context, st *probeState) {
+	ticker := time.NewTicker(durationOr(st.target.Interval, DefaultProbeInterval))
+	defer ticker.Stop()
+	for {
+		p.check(ctx, st)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check 执行一次探测并更新状态
+func (p *Prober) check(ctx context.Context, st *probeState) {
+	t := st.target
+	probeCtx, cancel := context.WithTimeout(ctx, durationOr(t.Timeout, DefaultProbeTimeout))
+	start := time.Now()
+	err := runProbe(probeCtx, t.Probe)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+
+	healthy, unhealthy := t.HealthyThreshold, t.UnhealthyThreshold
+	if healthy <= 0 {
+		healthy = 1
+	}
+	if unhealthy <= 0 {
+		unhealthy = DefaultUnhealthyThreshold
+	}
+
+	st.mu.Lock()
+	s := &st.status
+	s.LastCheck = start
+	s.Latency = time.Since(start)
+	s.LastError = ""
+	previous := s.State
+	if err == nil {
+		s.Successes++
+		s.Failures = 0
+		if s.Successes >= healthy {
+			s.State = ProbeHealthy
+		}
+	} else {
+		s.LastError = err.Error()
+		s.Failures++
+		s.Successes = 0
+		if s.Failures >= unhealthy {
+			s.State = ProbeUnhealthy
+		}
+	}
+	changed := s.State != previous
+	if changed {
+		s.Since = start
+	}
+	status := *s
+	st.mu.Unlock()
+
+	if changed {
+		if status.State == ProbeUnhealthy {
+			log.Println(fmt.Sprintf("[%s] %s -> %s, %s", t.Name, previous, status.State, status.LastError))
+		} else {
+			log.Println(fmt.Sprintf("[%s] %s -> %s", t.Name, previous, status.State))
+		}
+		if p.OnChange != nil {
+			p.OnChange(status, previous)
+		}
+	}
+}
+
+// runProbe 执行探测，panic视为失败
+func runProbe(ctx context.Context, probe Probe) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("probe panic: %v", r)
+		}
+	}()
+	return probe.Probe(ctx)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
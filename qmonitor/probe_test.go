@@ -0,0 +1,201 @@
+package qmonitor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"UP","version":"1.2.3"}`))
+	}))
+	defer srv.Close()
+	header := map[string]string{"X-Token": "t"}
+	ctx := context.Background()
+
+	for _, c := range []struct {
+		probe *HTTPProbe
+		err   string
+	}{
+		{&HTTPProbe{URL: srv.URL, Header: header}, ""},
+		{&HTTPProbe{URL: srv.URL, Header: header, BodyContains: `"UP"`, BodyMatch: regexp.MustCompile(`"version":"1\.`)}, ""},
+		{&HTTPProbe{URL: srv.URL}, "unexpected status 401"},
+		{&HTTPProbe{URL: srv.URL, ExpectStatus: []int{http.StatusUnauthorized}}, ""},
+		{&HTTPProbe{URL: srv.URL, Header: header, BodyContains: "DOWN"}, "does not contain"},
+		{&HTTPProbe{URL: srv.URL, Header: header, BodyMatch: regexp.MustCompile(`"version":"2\.`)}, "does not match"},
+	} {
+		err := c.probe.Probe(ctx)
+		if c.err == "" && err != nil || c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Fatalf("%+v: err = %v", c.probe, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	if err := (&HTTPProbe{URL: srv.URL + "/slow", Header: header}).Probe(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestHTTPProbeReusesConnection(t *testing.T) {
+	var conns atomic.Int32
+	// 响应内容超出客户端的读缓冲，未读完就关闭时连接不能复用
+	body := strings.Repeat("x", 32<<10)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+	probe := &HTTPProbe{URL: srv.URL, Client: client}
+	for i := 0; i < 3; i++ {
+		if err := probe.Probe(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Fatalf("conns = %d", n)
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	if err = (&TCPProbe{Addr: addr}).Probe(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_ = ln.Close()
+	if err = (&TCPProbe{Addr: addr}).Probe(context.Background()); err == nil {
+		t.Fatal("closed port should fail")
+	}
+}
+
+func TestCommandProbe(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-test.run=^TestWatchdogHelper$"}
+	ok := &CommandProbe{Path: exe, Args: args, OutputContains: "PASS"}
+	if err = ok.Probe(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	fail := &CommandProbe{Path: exe, Args: args, Env: map[string]string{"WATCHDOG_HELPER": "exit", "WATCHDOG_TAG": "probe"}}
+	if err = fail.Probe(context.Background()); err == nil || !strings.Contains(err.Error(), "exit status 3: helper started probe") {
+		t.Fatalf("err = %v", err)
+	}
+	ok.OutputContains = "FAIL"
+	if err = ok.Probe(context.Background()); err == nil {
+		t.Fatal("output should not match")
+	}
+}
+
+// scriptProbe 按顺序返回预设结果，用完后重复最后一个
+type scriptProbe struct {
+	mu      sync.Mutex
+	results []bool
+}
+
+func (s *scriptProbe) Probe(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ok := s.results[0]
+	if len(s.results) > 1 {
+		s.results = s.results[1:]
+	}
+	if !ok {
+		return errors.New("down")
+	}
+	return nil
+}
+
+func TestProberThreshold(t *testing.T) {
+	// 单次失败和单次成功不切换状态
+	script := &scriptProbe{results: []bool{true, true, false, true, true, false, false, false, true, false, true, true}}
+	p := NewProber(ProbeTarget{Name: "db", Probe: script, Interval: time.Millisecond * 5, HealthyThreshold: 2, UnhealthyThreshold: 3})
+	var mu sync.Mutex
+	var changes []string
+	p.OnChange = func(st ProbeStatus, previous ProbeState) {
+		mu.Lock()
+		changes = append(changes, previous.String()+"->"+st.State.String())
+		mu.Unlock()
+	}
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, time.Second*5, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(changes) == 3
+	})
+	p.Stop()
+	if err := p.Add(ProbeTarget{Name: "late", Probe: &TCPProbe{}}); err == nil {
+		t.Fatal("add after stop should fail")
+	}
+
+	if got := strings.Join(changes, " "); got != "unknown->healthy healthy->unhealthy unhealthy->healthy" {
+		t.Fatalf("changes = %s", got)
+	}
+	st := p.Status()[0]
+	if st.Name != "db" || st.State != ProbeHealthy || st.Failures != 0 || st.Successes < 2 || st.LastError != "" || st.LastCheck.IsZero() {
+		t.Fatalf("status = %+v", st)
+	}
+}
+
+func TestProberTimeoutAndPanic(t *testing.T) {
+	p := NewProber(
+		ProbeTarget{Name: "hang", Timeout: time.Millisecond * 20, UnhealthyThreshold: 1, Probe: ProbeFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})},
+		ProbeTarget{Name: "panic", UnhealthyThreshold: 1, Probe: ProbeFunc(func(ctx context.Context) error {
+			panic("boom")
+		})},
+	)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	if err := p.Start(context.Background()); err == nil {
+		t.Fatal("start twice should fail")
+	}
+	waitUntil(t, time.Second*5, func() bool {
+		list := p.Status()
+		return list[0].State == ProbeUnhealthy && list[1].State == ProbeUnhealthy
+	})
+	list := p.Status()
+	if !strings.Contains(list[0].LastError, "deadline exceeded") || list[1].LastError != "probe panic: boom" {
+		t.Fatalf("status = %+v", list)
+	}
+	if err := p.Add(ProbeTarget{Name: "hang", Probe: &TCPProbe{}}); err == nil {
+		t.Fatal("duplicate name should fail")
+	}
+}
@@ -0,0 +1,255 @@
+package qmonitor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrProcessNotFound 进程不存在
+var ErrProcessNotFound = errors.New("process not found")
+
+// clockTicks /proc中CPU时间的单位，Linux上USER_HZ固定为100
+const clockTicks = 100
+
+// Process 进程信息
+type Process struct {
+	PID        int
+	PPID       int
+	Name       string        // 进程名，Linux上最长15个字符
+	Cmdline    []string      // 启动命令及参数，内核线程为空
+	StartTime  time.Time     // 启动时间
+	State      string        // 状态，R运行 S睡眠 D不可中断 Z僵尸 T停止
+	CPUTime    time.Duration // 累计占用的CPU时间(用户态+内核态)
+	CPUPercent float64       // 启动以来的平均CPU占用率，100表示占满一个核
+	RSS        uint64        // 常驻内存字节数
+	FDCount    int           // 打开的文件描述符数量，无权限读取时为-1
+	Threads    int           // 线程数
+}
+
+// Executable 获取可执行文件名，优先使用命令行中的文件名，避免进程名被截断
+//
+//	@return string
+func (p Process) Executable() string {
+	if len(p.Cmdline) > 0 && p.Cmdline[0] != "" {
+		return filepath.Base(p.Cmdline[0])
+	}
+	return p.Name
+}
+
+// ProcFS 从proc文件系统读取进程信息
+type ProcFS struct {
+	Root string // proc文件系统的挂载点，默认为/proc，测试时可指向伪造的目录
+}
+
+// NewProcFS 创建proc文件系统读取器
+//
+//	@param root 挂载点，为空时使用/proc
+//	@return *ProcFS
+func NewProcFS(root string) *ProcFS {
+	if root == "" {
+		root = "/proc"
+	}
+	return &ProcFS{Root: root}
+}
+
+// Processes 获取所有进程，按PID排序，读取过程中退出的进程会被忽略
+//
+//	@return []Process
+//	@return error
+func (fs *ProcFS) Processes() ([]Process, error) {
+	entries, err := os.ReadDir(fs.Root)
+	if err != nil {
+		return nil, err
+	}
+	boot, uptime, err := fs.clock()
+	if err != nil {
+		return nil, err
+	}
+	var list []Process
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		p, err := fs.read(pid, boot, uptime)
+		if err != nil {
+			continue
+		}
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].PID < list[j].PID
+	})
+	return list, nil
+}
+
+// FindByPid 按进程Id获取进程
+//
+//	@param pid
+//	@return Process
+//	@return error 进程不存在时返回ErrProcessNotFound
+func (fs *ProcFS) FindByPid(pid int) (Process, error) {
+	boot, uptime, err := fs.clock()
+	if err != nil {
+		return Process{}, err
+	}
+	p, err := fs.read(pid, boot, uptime)
+	if errors.Is(err, os.ErrNotExist) {
+		return Process{}, ErrProcessNotFound
+	}
+	return p, err
+}
+
+// FindByName 按进程名查找进程，不区分大小写，同时匹配进程名和可执行文件名
+//
+//	@param name
+//	@return []Process
+//	@return error
+func (fs *ProcFS) FindByName(name string) ([]Process, error) {
+	list, err := fs.Processes()
+	if err != nil {
+		return nil, err
+	}
+	return filterByName(list, name), nil
+}
+
+// Children 获取直接子进程
+//
+//	@param pid
+//	@return []Process
+//	@return error
+func (fs *ProcFS) Children(pid int) ([]Process, error) {
+	list, err := fs.Processes()
+	if err != nil {
+		return nil, err
+	}
+	return filterChildren(list, pid), nil
+}
+
+// clock 读取系统启动时间和已运行时长
+func (fs *ProcFS) clock() (time.Time, time.Duration, error) {
+	data, err := os.ReadFile(filepath.Join(fs.Root, "stat"))
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	var boot time.Time
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			sec, err := strconv.ParseInt(strings.TrimSpace(line[6:]), 10, 64)
+			if err != nil {
+				return time.Time{}, 0, fmt.Errorf("parse btime: %w", err)
+			}
+			boot = time.Unix(sec, 0)
+		}
+	}
+	if boot.IsZero() {
+		return time.Time{}, 0, fmt.Errorf("btime not found in %s", filepath.Join(fs.Root, "stat"))
+	}
+
+	data, err = os.ReadFile(filepath.Join(fs.Root, "uptime"))
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return time.Time{}, 0, fmt.Errorf("invalid uptime")
+	}
+	sec, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("parse uptime: %w", err)
+	}
+	return boot, time.Duration(sec * float64(time.Second)), nil
+}
+
+// read 读取单个进程
+func (fs *ProcFS) read(pid int, boot time.Time, uptime time.Duration) (Process, error) {
+	dir := filepath.Join(fs.Root, strconv.Itoa(pid))
+	data, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return Process{}, err
+	}
+	p, err := parseStat(data, boot, uptime)
+	if err != nil {
+		return Process{}, fmt.Errorf("pid %d: %w", pid, err)
+	}
+
+	if data, err = os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		data = bytes.TrimRight(data, "\x00")
+		if len(data) > 0 {
+			p.Cmdline = strings.Split(string(data), "\x00")
+		}
+	}
+	p.FDCount = -1
+	if fds, err := os.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		p.FDCount = len(fds)
+	}
+	return p, nil
+}
+
+// parseStat 解析 /proc/<pid>/stat
+//
+//	格式为 pid (comm) state ppid ...，comm中可能包含空格和括号，以最后一个右括号为界
+func parseStat(data []byte, boot time.Time, uptime time.Duration) (Process, error) {
+	s := string(data)
+	l := strings.IndexByte(s, '(')
+	r := strings.LastIndexByte(s, ')')
+	if l < 0 || r < l {
+		return Process{}, fmt.Errorf("invalid stat")
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(s[:l]))
+	if err != nil {
+		return Process{}, fmt.Errorf("invalid stat pid: %w", err)
+	}
+	// fields[0]为第3个字段state
+	fields := strings.Fields(s[r+1:])
+	if len(fields) < 22 {
+		return Process{}, fmt.Errorf("invalid stat, %d fields", len(fields))
+	}
+	num := func(i int) uint64 {
+		v, _ := strconv.ParseUint(fields[i], 10, 64)
+		return v
+	}
+
+	p := Process{
+		PID:     pid,
+		PPID:    int(num(1)),
+		Name:    s[l+1 : r],
+		State:   fields[0],
+		CPUTime: time.Duration(num(11)+num(12)) * time.Second / clockTicks,
+		Threads: int(num(17)),
+		RSS:     num(21) * uint64(os.Getpagesize()),
+	}
+	started := time.Duration(num(19)) * time.Second / clockTicks
+	p.StartTime = boot.Add(started)
+	if elapsed := uptime - started; elapsed > 0 {
+		p.CPUPercent = float64(p.CPUTime) / float64(elapsed) * 100
+	}
+	return p, nil
+}
+
+func filterByName(list []Process, name string) []Process {
+	var result []Process
+	for _, p := range list {
+		if strings.EqualFold(p.Name, name) || strings.EqualFold(p.Executable(), name) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func filterChildren(list []Process, pid int) []Process {
+	var result []Process
+	for _, p := range list {
+		if p.PPID == pid && p.PID != pid {
+			result = append(result, p)
+		}
+	}
+	return result
+}
@@ -0,0 +1,214 @@
+package qmonitor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const fakeBootTime = 1700000000
+
+// fakeProc 伪造的/proc目录
+type fakeProc struct {
+	t    *testing.T
+	root string
+}
+
+// fakeProcess 伪造进程的参数
+type fakeProcess struct {
+	pid, ppid int
+	comm      string
+	state     string
+	cmdline   []string
+	utime     int // CPU时间，单位为时钟周期
+	stime     int
+	threads   int
+	start     int // 系统启动后多少个时钟周期启动
+	rssPages  int
+	fds       int
+}
+
+func newFakeProc(t *testing.T, uptime float64) *fakeProc {
+	fp := &fakeProc{t: t, root: t.TempDir()}
+	fp.write("stat", fmt.Sprintf("cpu  1 2 3 4 5 6 7 0 0 0\nbtime %d\nprocesses 100\n", fakeBootTime))
+	fp.write("uptime", fmt.Sprintf("%.2f 1000.00\n", uptime))
+	return fp
+}
+
+func (fp *fakeProc) write(name, content string) {
+	fp.t.Helper()
+	file := filepath.Join(fp.root, name)
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		fp.t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		fp.t.Fatal(err)
+	}
+}
+
+func (fp *fakeProc) add(p fakeProcess) {
+	if p.state == "" {
+		p.state = "S"
+	}
+	if p.threads == 0 {
+		p.threads = 1
+	}
+	dir := fmt.Sprint(p.pid)
+	// pid (comm) state ppid pgrp session tty tpgid flags minflt cminflt majflt cmajflt
+	// utime stime cutime cstime priority nice num_threads itrealvalue starttime vsize rss ...
+	fp.write(dir+"/stat", fmt.Sprintf("%d (%s) %s %d %d %d 0 -1 4194560 100 0 0 0 %d %d 0 0 20 0 %d 0 %d 12345678 %d 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n",
+		p.pid, p.comm, p.state, p.ppid, p.pid, p.pid, p.utime, p.stime, p.threads, p.start, p.rssPages))
+	fp.write(dir+"/cmdline", strings.Join(p.cmdline, "\x00"))
+	if err := os.MkdirAll(filepath.Join(fp.root, dir, "fd"), os.ModePerm); err != nil {
+		fp.t.Fatal(err)
+	}
+	for i := 0; i < p.fds; i++ {
+		fp.write(fmt.Sprintf("%s/fd/%d", dir, i), "")
+	}
+}
+
+func (fp *fakeProc) fs() *ProcFS {
+	return NewProcFS(fp.root)
+}
+
+// newFakeTree 伪造的进程树：
+//
+//	1 systemd
+//	├── 100 gateway (2个子进程 200、201)
+//	└── 300 (sd-pam) 名称包含空格和括号
+func newFakeTree(t *testing.T) *fakeProc {
+	fp := newFakeProc(t, 1000)
+	fp.add(fakeProcess{pid: 1, ppid: 0, comm: "systemd", cmdline: []string{"/sbin/init", "splash"}, utime: 500, stime: 500, fds: 3})
+	fp.add(fakeProcess{pid: 100, ppid: 1, comm: "gateway", cmdline: []string{"/opt/gw/gateway", "-c", "config.yaml"},
+		utime: 30000, stime: 20000, threads: 12, start: 50000, rssPages: 256, fds: 5})
+	fp.add(fakeProcess{pid: 200, ppid: 100, comm: "very_long_proce", cmdline: []string{"/opt/gw/very_long_process_name"}, start: 60000})
+	fp.add(fakeProcess{pid: 201, ppid: 100, comm: "sh", state: "Z", start: 60000})
+	fp.add(fakeProcess{pid: 300, ppid: 1, comm: "(sd-pam) x", start: 100})
+	// 非进程目录
+	fp.write("net/tcp", "")
+	fp.write("self", "")
+	return fp
+}
+
+func TestProcFSProcesses(t *testing.T) {
+	list, err := newFakeTree(t).fs().Processes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pids []int
+	for _, p := range list {
+		pids = append(pids, p.PID)
+	}
+	if fmt.Sprint(pids) != "[1 100 200 201 300]" {
+		t.Fatalf("pids = %v", pids)
+	}
+}
+
+func TestProcFSFindByPid(t *testing.T) {
+	fs := newFakeTree(t).fs()
+	p, err := fs.FindByPid(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PPID != 1 || p.Name != "gateway" || p.State != "S" || p.Threads != 12 || p.FDCount != 5 {
+		t.Fatalf("process = %+v", p)
+	}
+	if strings.Join(p.Cmdline, " ") != "/opt/gw/gateway -c config.yaml" {
+		t.Fatalf("cmdline = %q", p.Cmdline)
+	}
+	if p.RSS != uint64(256*os.Getpagesize()) {
+		t.Fatalf("rss = %d", p.RSS)
+	}
+	// 系统启动500秒后启动
+	if !p.StartTime.Equal(time.Unix(fakeBootTime+500, 0)) {
+		t.Fatalf("start time = %v", p.StartTime)
+	}
+	// 运行500秒，占用CPU 500秒
+	if p.CPUTime != time.Second*500 || p.CPUPercent < 99.9 || p.CPUPercent > 100.1 {
+		t.Fatalf("cpu = %v %.2f", p.CPUTime, p.CPUPercent)
+	}
+
+	p, err = fs.FindByPid(300)
+	if err != nil || p.Name != "(sd-pam) x" || p.PPID != 1 {
+		t.Fatalf("process = %+v %v", p, err)
+	}
+
+	if _, err = fs.FindByPid(999); !errors.Is(err, ErrProcessNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestProcFSFindByName(t *testing.T) {
+	fs := newFakeTree(t).fs()
+	for name, want := range map[string]int{
+		"GATEWAY":                100,
+		"very_long_process_name": 200, // 进程名被截断时按可执行文件名匹配
+		"very_long_proce":        200,
+		"(sd-pam) x":             300,
+	} {
+		list, err := fs.FindByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].PID != want {
+			t.Fatalf("%s = %+v", name, list)
+		}
+	}
+	if list, _ := fs.FindByName("nginx"); len(list) != 0 {
+		t.Fatalf("nginx = %+v", list)
+	}
+}
+
+func TestProcFSChildren(t *testing.T) {
+	fs := newFakeTree(t).fs()
+	list, err := fs.Children(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].PID != 200 || list[1].PID != 201 || list[1].State != "Z" {
+		t.Fatalf("children = %+v", list)
+	}
+	if list, _ = fs.Children(1); len(list) != 2 {
+		t.Fatalf("children of 1 = %+v", list)
+	}
+}
+
+func TestProcFSInvalid(t *testing.T) {
+	fp := newFakeProc(t, 10)
+	fp.write("5/stat", "5 broken")
+	if list, err := fp.fs().Processes(); err != nil || len(list) != 0 {
+		t.Fatalf("list = %+v %v", list, err)
+	}
+	if _, err := NewProcFS(t.TempDir()).Processes(); err == nil {
+		t.Fatal("missing stat should fail")
+	}
+}
+
+func TestFindByPidSelf(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux only")
+	}
+	p, err := FindByPid(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PPID != os.Getppid() || p.Threads < 1 || p.RSS == 0 || p.FDCount < 3 || len(p.Cmdline) == 0 {
+		t.Fatalf("self = %+v", p)
+	}
+	children, err := Children(os.Getppid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, c := range children {
+		found = found || c.PID == os.Getpid()
+	}
+	if !found {
+		t.Fatal("self not found in children of parent")
+	}
+}
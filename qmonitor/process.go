@@ -36,3 +36,73 @@ func GetProcessCountByPid(pid int) int {
 	}
 	return count
 }
+
+// Processes 获取所有进程，按PID排序
+//
+//	Linux上从/proc读取完整信息，其他系统只有PID、PPID和进程名
+//	@return []Process
+//	@return error
+func Processes() ([]Process, error) {
+	return processes()
+}
+
+// FindByPid 按进程Id获取进程
+//
+//	@param pid
+//	@return Process
+//	@return error 进程不存在时返回ErrProcessNotFound
+func FindByPid(pid int) (Process, error) {
+	return findByPid(pid)
+}
+
+// FindByName 按进程名查找进程，不区分大小写
+//
+//	@param name
+//	@return []Process
+//	@return error
+func FindByName(name string) ([]Process, error) {
+	list, err := processes()
+	if err != nil {
+		return nil, err
+	}
+	return filterByName(list, name), nil
+}
+
+// Children 获取直接子进程
+//
+//	@param pid
+//	@return []Process
+//	@return error
+func Children(pid int) ([]Process, error) {
+	list, err := processes()
+	if err != nil {
+		return nil, err
+	}
+	return filterChildren(list, pid), nil
+}
+
+// Sockets 获取所有TCP、UDP连接并关联所属进程，仅Linux
+//
+//	@return []Socket
+//	@return error
+func Sockets() ([]Socket, error) {
+	return sockets()
+}
+
+// ListenersOn 获取监听指定端口的TCP和UDP连接，用于查找占用端口的进程，仅Linux
+//
+//	@param port
+//	@return []Socket
+//	@return error
+func ListenersOn(port int) ([]Socket, error) {
+	return listenersOn(port)
+}
+
+// SocketsOf 获取进程打开的所有TCP、UDP连接，仅Linux
+//
+//	@param pid
+//	@return []Socket
+//	@return error 进程不存在时返回ErrProcessNotFound
+func SocketsOf(pid int) ([]Socket, error) {
+	return socketsOf(pid)
+}
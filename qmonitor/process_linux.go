@@ -0,0 +1,25 @@
+//go:build linux
+
+package qmonitor
+
+var procFS = NewProcFS("")
+
+func processes() ([]Process, error) {
+	return procFS.Processes()
+}
+
+func findByPid(pid int) (Process, error) {
+	return procFS.FindByPid(pid)
+}
+
+func sockets() ([]Socket, error) {
+	return procFS.Sockets()
+}
+
+func listenersOn(port int) ([]Socket, error) {
+	return procFS.ListenersOn(port)
+}
+
+func socketsOf(pid int) ([]Socket, error) {
+	return procFS.SocketsOf(pid)
+}
@@ -0,0 +1,54 @@
+//go:build !linux
+
+package qmonitor
+
+import (
+	"errors"
+	"github.com/mitchellh/go-ps"
+	"sort"
+)
+
+var errSocketsUnsupported = errors.New("socket inspection is only supported on linux")
+
+// processes 非Linux系统只能获取PID、PPID和进程名
+func processes() ([]Process, error) {
+	list, err := ps.Processes()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Process, 0, len(list))
+	for _, p := range list {
+		result = append(result, fromPs(p))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PID < result[j].PID
+	})
+	return result, nil
+}
+
+func findByPid(pid int) (Process, error) {
+	p, err := ps.FindProcess(pid)
+	if err != nil {
+		return Process{}, err
+	}
+	if p == nil {
+		return Process{}, ErrProcessNotFound
+	}
+	return fromPs(p), nil
+}
+
+func fromPs(p ps.Process) Process {
+	return Process{PID: p.Pid(), PPID: p.PPid(), Name: p.Executable(), FDCount: -1}
+}
+
+func sockets() ([]Socket, error) {
+	return nil, errSocketsUnsupported
+}
+
+func listenersOn(port int) ([]Socket, error) {
+	return nil, errSocketsUnsupported
+}
+
+func socketsOf(pid int) ([]Socket, error) {
+	return nil, errSocketsUnsupported
+}
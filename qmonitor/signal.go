@@ -0,0 +1,232 @@
+package qmonitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// ErrWaitTimeout 等待进程退出超时
+var ErrWaitTimeout = errors.New("wait for process exit timeout")
+
+// waitPollInterval 等待进程退出时的检查间隔
+const waitPollInterval = time.Millisecond * 20
+
+// Descendants 获取所有后代进程，父进程排在子进程之前
+//
+//	@param pid
+//	@return []Process
+//	@return error
+func Descendants(pid int) ([]Process, error) {
+	list, err := processes()
+	if err != nil {
+		return nil, err
+	}
+	return filterDescendants(list, pid), nil
+}
+
+// Descendants 获取所有后代进程，父进程排在子进程之前
+//
+//	@param pid
+//	@return []Process
+//	@return error
+func (fs *ProcFS) Descendants(pid int) ([]Process, error) {
+	list, err := fs.Processes()
+	if err != nil {
+		return nil, err
+	}
+	return filterDescendants(list, pid), nil
+}
+
+// Signal 向进程发送信号
+//
+//	@param pid
+//	@param sig Windows只支持SIGKILL
+//	@return error 进程不存在时返回ErrProcessNotFound
+func Signal(pid int, sig syscall.Signal) error {
+	if pid <= 0 {
+		return fmt.Errorf("invalid pid %d", pid)
+	}
+	return signalPid(pid, sig)
+}
+
+// SignalGroup 向进程所在的进程组发送信号，仅Unix
+//
+//	@param pid 进程组中的任一进程
+//	@param sig
+//	@return error 进程与当前进程同组时返回错误
+func SignalGroup(pid int, sig syscall.Signal) error {
+	if pid <= 0 {
+		return fmt.Errorf("invalid pid %d", pid)
+	}
+	return signalGroup(pid, sig)
+}
+
+// Terminate 发送终止信号，等待grace后仍未退出则强制结束
+//
+//	@param pid
+//	@param grace 等待进程自行退出的时间，Windows上直接强制结束
+//	@return error 进程不存在时返回ErrProcessNotFound
+func Terminate(pid int, grace time.Duration) error {
+	p, err := findByPid(pid)
+	if err != nil {
+		return err
+	}
+	return terminateAll([]Process{p}, grace)
+}
+
+// TerminateTree 终止进程及其所有后代进程，先发送终止信号，等待grace后强制结束仍存活的进程
+//
+//	@param pid
+//	@param grace
+//	@return error 根进程不存在时返回ErrProcessNotFound
+func TerminateTree(pid int, grace time.Duration) error {
+	tree, err := processTree(pid)
+	if err != nil {
+		return err
+	}
+	return terminateAll(tree, grace)
+}
+
+// KillTree 强制结束进程及其所有后代进程并等待退出
+//
+//	@param pid
+//	@param timeout 等待退出的时间
+//	@return error 根进程不存在时返回ErrProcessNotFound
+func KillTree(pid int, timeout time.Duration) error {
+	tree, err := processTree(pid)
+	if err != nil {
+		return err
+	}
+	// 先结束父进程，避免其继续创建子进程
+	for _, p := range tree {
+		if err = signalAlive(p, syscall.SIGKILL); err != nil {
+			return fmt.Errorf("kill %d: %w", p.PID, err)
+		}
+	}
+	return waitAll(tree, timeout)
+}
+
+// WaitExit 等待进程退出，僵尸进程视为已退出
+//
+//	@param pid
+//	@param timeout
+//	@return error 超时返回ErrWaitTimeout
+func WaitExit(pid int, timeout time.Duration) error {
+	p, err := findByPid(pid)
+	if errors.Is(err, ErrProcessNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return waitAll([]Process{p}, timeout)
+}
+
+// processTree 获取进程及其所有后代进程
+func processTree(pid int) ([]Process, error) {
+	list, err := processes()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range list {
+		if p.PID == pid {
+			return append([]Process{p}, filterDescendants(list, pid)...), nil
+		}
+	}
+	return nil, ErrProcessNotFound
+}
+
+// terminateAll 从叶子进程开始发送终止信号，超时后强制结束仍存活的进程
+func terminateAll(tree []Process, grace time.Duration) error {
+	for i := len(tree) - 1; i >= 0; i-- {
+		if err := signalAlive(tree[i], terminateSignal); err != nil {
+			return fmt.Errorf("terminate %d: %w", tree[i].PID, err)
+		}
+	}
+	if err := waitAll(tree, grace); err == nil {
+		return nil
+	}
+	for _, p := range tree {
+		if err := signalAlive(p, syscall.SIGKILL); err != nil {
+			return fmt.Errorf("kill %d: %w", p.PID, err)
+		}
+	}
+	return waitAll(tree, grace+time.Second*5)
+}
+
+// signalAlive 确认进程仍是记录中的进程后发送信号，已退出或PID已被复用时跳过
+func signalAlive(p Process, sig syscall.Signal) error {
+	if !alive(p) {
+		return nil
+	}
+	if err := signalPid(p.PID, sig); err != nil && !errors.Is(err, ErrProcessNotFound) {
+		return err
+	}
+	return nil
+}
+
+// waitAll 等待所有进程退出
+func waitAll(tree []Process, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for {
+		running := false
+		for _, p := range tree {
+			if alive(p) {
+				running = true
+				break
+			}
+		}
+		if !running {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ErrWaitTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// alive 进程是否仍在运行，僵尸进程和PID被复用的视为已退出
+func alive(p Process) bool {
+	cur, err := findByPid(p.PID)
+	if err != nil {
+		return false
+	}
+	if cur.State == "Z" || cur.State == "X" {
+		return false
+	}
+	return p.StartTime.IsZero() || cur.StartTime.Equal(p.StartTime)
+}
+
+// filterDescendants 按层级筛选后代进程
+func filterDescendants(list []Process, pid int) []Process {
+	children := map[int][]Process{}
+	for _, p := range list {
+		if p.PID != p.PPID {
+			children[p.PPID] = append(children[p.PPID], p)
+		}
+	}
+	var result []Process
+	queue := []int{pid}
+	seen := map[int]bool{pid: true}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, c := range children[parent] {
+			if seen[c.PID] {
+				continue
+			}
+			seen[c.PID] = true
+			result = append(result, c)
+			queue = append(queue, c.PID)
+		}
+	}
+	return result
+}
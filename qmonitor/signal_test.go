@@ -0,0 +1,45 @@
+package qmonitor
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestProcFSDescendants(t *testing.T) {
+	fp := newFakeTree(t)
+	fp.add(fakeProcess{pid: 400, ppid: 200, comm: "worker"})
+	fs := fp.fs()
+	for pid, want := range map[int]string{
+		1:   "[100 300 200 201 400]",
+		100: "[200 201 400]",
+		400: "[]",
+	} {
+		list, err := fs.Descendants(pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pids []int
+		for _, p := range list {
+			pids = append(pids, p.PID)
+		}
+		if fmt.Sprint(pids) != want {
+			t.Fatalf("descendants of %d = %v, want %s", pid, pids, want)
+		}
+	}
+}
+
+func TestTerminateNotFound(t *testing.T) {
+	for _, err := range []error{Terminate(1<<22+1, time.Second), TerminateTree(1<<22+1, time.Second), KillTree(1<<22+1, time.Second)} {
+		if !errors.Is(err, ErrProcessNotFound) {
+			t.Fatalf("err = %v", err)
+		}
+	}
+	if err := WaitExit(1<<22+1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := Signal(0, 0); err == nil {
+		t.Fatal("pid 0 should fail")
+	}
+}
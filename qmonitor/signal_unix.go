@@ -0,0 +1,38 @@
+//go:build !windows
+
+package qmonitor
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// terminateSignal 请求进程退出的信号
+const terminateSignal = syscall.SIGTERM
+
+func signalPid(pid int, sig syscall.Signal) error {
+	err := syscall.Kill(pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return ErrProcessNotFound
+	}
+	return err
+}
+
+func signalGroup(pid int, sig syscall.Signal) error {
+	pgid, err := syscall.Getpgid(pid)
+	if errors.Is(err, syscall.ESRCH) {
+		return ErrProcessNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if pgid == syscall.Getpgrp() {
+		return fmt.Errorf("process %d is in the same process group as the current process", pid)
+	}
+	err = syscall.Kill(-pgid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return ErrProcessNotFound
+	}
+	return err
+}
@@ -0,0 +1,204 @@
+//go:build !windows
+
+package qmonitor
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestSignalHelper 信号测试的子进程
+//
+//	sleep 等待被结束；ignore 忽略SIGTERM；tree 启动两个子进程后等待
+func TestSignalHelper(t *testing.T) {
+	mode := os.Getenv("SIGNAL_HELPER")
+	if mode == "" {
+		t.Skip("helper process")
+	}
+	switch mode {
+	case "ignore":
+		signal.Ignore(syscall.SIGTERM)
+	case "tree":
+		for i := 0; i < 2; i++ {
+			cmd, out := signalHelper(os.Getenv("SIGNAL_CHILD"))
+			if err := cmd.Start(); err != nil {
+				os.Exit(2)
+			}
+			// 等待子进程就绪
+			_, _ = bufio.NewReader(out).ReadString('\n')
+		}
+	}
+	os.Stdout.WriteString("ready\n")
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+func signalHelper(mode string) (*exec.Cmd, io.Reader) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSignalHelper$")
+	cmd.Env = append(os.Environ(), "SIGNAL_HELPER="+mode)
+	out, _ := cmd.StdoutPipe()
+	return cmd, out
+}
+
+// startHelper 启动子进程并等待就绪，返回进程退出的通知
+func startHelper(t *testing.T, mode, child string, setpgid bool) (int, chan error) {
+	t.Helper()
+	cmd, out := signalHelper(mode)
+	cmd.Env = append(cmd.Env, "SIGNAL_CHILD="+child)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: setpgid}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = KillTree(cmd.Process.Pid, time.Second*5) })
+	if line, err := bufio.NewReader(out).ReadString('\n'); err != nil || line != "ready\n" {
+		t.Fatalf("helper = %q %v", line, err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	return cmd.Process.Pid, exited
+}
+
+func descendantPids(t *testing.T, pid int) []int {
+	t.Helper()
+	list, err := Descendants(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pids []int
+	for _, p := range list {
+		pids = append(pids, p.PID)
+	}
+	return pids
+}
+
+func assertExited(t *testing.T, exited chan error, sig syscall.Signal) {
+	t.Helper()
+	select {
+	case err := <-exited:
+		var ee *exec.ExitError
+		if !errors.As(err, &ee) || ee.Sys().(syscall.WaitStatus).Signal() != sig {
+			t.Fatalf("exit = %v, want %v", err, sig)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("process not exited")
+	}
+}
+
+func TestTerminateTree(t *testing.T) {
+	pid, exited := startHelper(t, "tree", "sleep", false)
+	children := descendantPids(t, pid)
+	if len(children) != 2 {
+		t.Fatalf("children = %v", children)
+	}
+	start := time.Now()
+	if err := TerminateTree(pid, time.Second*5); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Second*3 {
+		t.Fatal("processes should exit on SIGTERM")
+	}
+	assertExited(t, exited, syscall.SIGTERM)
+	for _, c := range children {
+		if err := WaitExit(c, time.Millisecond*10); err != nil {
+			t.Fatalf("child %d: %v", c, err)
+		}
+	}
+}
+
+func TestTerminateTreeGrace(t *testing.T) {
+	// 子进程忽略SIGTERM，等待grace后强制结束
+	pid, exited := startHelper(t, "tree", "ignore", false)
+	children := descendantPids(t, pid)
+	if len(children) != 2 {
+		t.Fatalf("children = %v", children)
+	}
+	if err := WaitExit(children[0], time.Millisecond*50); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("err = %v", err)
+	}
+	start := time.Now()
+	if err := TerminateTree(pid, time.Millisecond*200); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < time.Millisecond*200 {
+		t.Fatal("should wait for grace period")
+	}
+	assertExited(t, exited, syscall.SIGTERM)
+	for _, c := range children {
+		if err := WaitExit(c, time.Millisecond*10); err != nil {
+			t.Fatalf("child %d: %v", c, err)
+		}
+	}
+}
+
+func TestKillTree(t *testing.T) {
+	pid, exited := startHelper(t, "tree", "ignore", false)
+	children := descendantPids(t, pid)
+	if err := KillTree(pid, time.Second*5); err != nil {
+		t.Fatal(err)
+	}
+	assertExited(t, exited, syscall.SIGKILL)
+	for _, c := range children {
+		if err := WaitExit(c, time.Millisecond*10); err != nil {
+			t.Fatalf("child %d: %v", c, err)
+		}
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	pid, exited := startHelper(t, "ignore", "", false)
+	if err := Signal(pid, syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if err := Terminate(pid, time.Millisecond*100); err != nil {
+		t.Fatal(err)
+	}
+	assertExited(t, exited, syscall.SIGKILL)
+}
+
+func TestSignalGroup(t *testing.T) {
+	if err := SignalGroup(os.Getpid(), syscall.SIGTERM); err == nil {
+		t.Fatal("signalling own group should fail")
+	}
+	pid, exited := startHelper(t, "tree", "sleep", true)
+	children := descendantPids(t, pid)
+	if err := SignalGroup(pid, syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	assertExited(t, exited, syscall.SIGKILL)
+	for _, c := range children {
+		if err := WaitExit(c, time.Second*5); err != nil {
+			t.Fatalf("child %d: %v", c, err)
+		}
+	}
+}
+
+func TestSignalAliveSkipsReusedPid(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	p, err := findByPid(cmd.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 记录中的启动时间与当前进程不一致，视为PID已被复用，不发送信号
+	p.StartTime = p.StartTime.Add(-time.Hour)
+	if err = signalAlive(p, syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(waitPollInterval)
+	if cur, err := findByPid(cmd.Process.Pid); err != nil || cur.State == "Z" {
+		t.Fatalf("process signalled, state = %q, err = %v", cur.State, err)
+	}
+}
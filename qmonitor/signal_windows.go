@@ -0,0 +1,33 @@
+//go:build windows
+
+package qmonitor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// terminateSignal Windows没有终止信号，直接强制结束
+const terminateSignal = syscall.SIGKILL
+
+func signalPid(pid int, sig syscall.Signal) error {
+	if sig != syscall.SIGKILL {
+		return fmt.Errorf("signal %v is not supported on windows", sig)
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return ErrProcessNotFound
+	}
+	defer p.Release()
+	err = p.Kill()
+	if errors.Is(err, os.ErrProcessDone) {
+		return ErrProcessNotFound
+	}
+	return err
+}
+
+func signalGroup(pid int, sig syscall.Signal) error {
+	return errors.New("process group signalling is not supported on windows")
+}
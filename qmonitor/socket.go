@@ -0,0 +1,251 @@
+package qmonitor
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// tcpStates /proc/net/tcp中st字段对应的TCP状态
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+// Socket 网络连接
+type Socket struct {
+	Proto      string // tcp、tcp6、udp、udp6
+	LocalIP    net.IP
+	LocalPort  int
+	RemoteIP   net.IP
+	RemotePort int
+	State      string // TCP状态，如LISTEN、ESTABLISHED；未连接的UDP为CLOSE
+	UID        int
+	Inode      uint64
+	PID        int // 所属进程，TIME_WAIT等无inode的连接或无权限读取其他用户进程时为0
+}
+
+// Listening 是否为监听中的TCP端口或已绑定但未连接的UDP端口
+func (s Socket) Listening() bool {
+	if strings.HasPrefix(s.Proto, "udp") {
+		return s.RemotePort == 0
+	}
+	return s.State == "LISTEN"
+}
+
+func (s Socket) String() string {
+	local := net.JoinHostPort(s.LocalIP.String(), strconv.Itoa(s.LocalPort))
+	remote := net.JoinHostPort(s.RemoteIP.String(), strconv.Itoa(s.RemotePort))
+	return fmt.Sprintf("%s %s -> %s %s pid %d", s.Proto, local, remote, s.State, s.PID)
+}
+
+// Sockets 获取所有TCP、UDP连接并关联所属进程，按协议、本地端口排序
+//
+//	@return []Socket
+//	@return error
+func (fs *ProcFS) Sockets() ([]Socket, error) {
+	list, err := fs.readSockets()
+	if err != nil {
+		return nil, err
+	}
+	owners, err := fs.socketOwners(0)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].PID = owners[list[i].Inode]
+	}
+	return list, nil
+}
+
+// ListenersOn 获取监听指定端口的TCP和UDP连接，用于查找占用端口的进程
+//
+//	@param port
+//	@return []Socket
+//	@return error
+func (fs *ProcFS) ListenersOn(port int) ([]Socket, error) {
+	list, err := fs.Sockets()
+	if err != nil {
+		return nil, err
+	}
+	var result []Socket
+	for _, s := range list {
+		if s.LocalPort == port && s.Listening() {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+// SocketsOf 获取进程打开的所有TCP、UDP连接
+//
+//	@param pid
+//	@return []Socket
+//	@return error 进程不存在时返回ErrProcessNotFound
+func (fs *ProcFS) SocketsOf(pid int) ([]Socket, error) {
+	if _, err := os.Stat(filepath.Join(fs.Root, strconv.Itoa(pid))); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrProcessNotFound
+	}
+	owners, err := fs.socketOwners(pid)
+	if err != nil {
+		return nil, err
+	}
+	list, err := fs.readSockets()
+	if err != nil {
+		return nil, err
+	}
+	var result []Socket
+	for _, s := range list {
+		if s.Inode != 0 && owners[s.Inode] == pid {
+			s.PID = pid
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+// readSockets 读取 /proc/net/{tcp,tcp6,udp,udp6}，不存在的文件(如未启用IPv6)被忽略
+func (fs *ProcFS) readSockets() ([]Socket, error) {
+	var list []Socket
+	for _, proto := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		data, err := os.ReadFile(filepath.Join(fs.Root, "net", proto))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			if s, ok := parseSocket(proto, line); ok {
+				list = append(list, s)
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Proto != list[j].Proto {
+			return list[i].Proto < list[j].Proto
+		}
+		return list[i].LocalPort < list[j].LocalPort
+	})
+	return list, nil
+}
+
+// parseSocket 解析 /proc/net/tcp 的一行
+//
+//	sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+func parseSocket(proto, line string) (Socket, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 10 {
+		return Socket{}, false
+	}
+	s := Socket{Proto: proto}
+	var ok bool
+	if s.LocalIP, s.LocalPort, ok = parseSocketAddr(fields[1]); !ok {
+		return Socket{}, false
+	}
+	if s.RemoteIP, s.RemotePort, ok = parseSocketAddr(fields[2]); !ok {
+		return Socket{}, false
+	}
+	if s.State, ok = tcpStates[strings.ToUpper(fields[3])]; !ok {
+		s.State = fields[3]
+	}
+	s.UID, _ = strconv.Atoi(fields[7])
+	s.Inode, _ = strconv.ParseUint(fields[9], 10, 64)
+	return s, true
+}
+
+// hostByteOrder 主机字节序，go1.20没有binary.NativeEndian
+var hostByteOrder binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// parseSocketAddr 解析十六进制的地址和端口，如 0100007F:1F90
+func parseSocketAddr(s string) (net.IP, int, bool) {
+	return decodeSocketAddr(s, hostByteOrder)
+}
+
+// decodeSocketAddr 按指定的主机字节序解析地址和端口
+//
+//	内核将地址按32位分组以主机字节序的整数输出，端口为数值
+func decodeSocketAddr(s string, order binary.ByteOrder) (net.IP, int, bool) {
+	host, port, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, false
+	}
+	raw, err := hex.DecodeString(host)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, false
+	}
+	for i := 0; i < len(raw); i += 4 {
+		order.PutUint32(raw[i:], binary.BigEndian.Uint32(raw[i:]))
+	}
+	p, err := strconv.ParseUint(port, 16, 16)
+	if err != nil {
+		return nil, 0, false
+	}
+	return net.IP(raw), int(p), true
+}
+
+// socketOwners 读取进程的fd，返回socket inode到PID的映射，pid为0时读取所有进程
+func (fs *ProcFS) socketOwners(pid int) (map[uint64]int, error) {
+	var pids []int
+	if pid > 0 {
+		pids = []int{pid}
+	} else {
+		entries, err := os.ReadDir(fs.Root)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if p, err := strconv.Atoi(e.Name()); err == nil && e.IsDir() {
+				pids = append(pids, p)
+			}
+		}
+		// 共享的socket(如fork后继承)归属于PID最小的进程
+		sort.Ints(pids)
+	}
+	owners := map[uint64]int{}
+	for _, p := range pids {
+		dir := filepath.Join(fs.Root, strconv.Itoa(p), "fd")
+		// 无权限或进程已退出时跳过
+		fds, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(dir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+			if _, ok := owners[inode]; !ok {
+				owners[inode] = p
+			}
+		}
+	}
+	return owners, nil
+}
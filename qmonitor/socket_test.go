@@ -0,0 +1,167 @@
+package qmonitor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+const fakeTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:C350 01 00000000:00000000 00:00000000 00000000  1000        0 1002 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:C350 0100007F:1F90 01 00000000:00000000 00:00000000 00000000  1000        0 1003 1 0000000000000000 20 4 30 10 -1
+   3: 0100007F:C351 0100007F:1F90 06 00000000:00000000 03:00000F9C 00000000     0        0 0 3 0000000000000000
+`
+
+const fakeTCP6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000001000000:0050 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 2001 1 0000000000000000 100 0 0 10 0
+`
+
+const fakeUDP = `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  100: 00000000:14E9 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 3001 2 0000000000000000 0
+`
+
+// socket 伪造进程打开的socket
+func (fp *fakeProc) socket(pid, fd int, inode uint64) {
+	fp.t.Helper()
+	link := filepath.Join(fp.root, fmt.Sprint(pid), "fd", fmt.Sprint(fd))
+	if err := os.Symlink(fmt.Sprintf("socket:[%d]", inode), link); err != nil {
+		fp.t.Skip("symlink not supported:", err)
+	}
+}
+
+// newFakeSockets 伪造的网络连接：
+//
+//	100 gateway 监听 0.0.0.0:8080、udp 5353，接受了来自200的连接
+//	200 通过 127.0.0.1:50000 连接 8080
+//	201 继承了100的监听socket
+//	300 监听 [::1]:80
+func newFakeSockets(t *testing.T) *fakeProc {
+	fp := newFakeTree(t)
+	fp.write("net/tcp", fakeTCP)
+	fp.write("net/tcp6", fakeTCP6)
+	fp.write("net/udp", fakeUDP)
+	fp.socket(100, 10, 1001)
+	fp.socket(100, 11, 1002)
+	fp.socket(100, 12, 3001)
+	fp.socket(200, 3, 1003)
+	fp.socket(201, 3, 1001)
+	fp.socket(300, 4, 2001)
+	if err := os.Symlink("pipe:[9999]", filepath.Join(fp.root, "300", "fd", "5")); err != nil {
+		t.Fatal(err)
+	}
+	return fp
+}
+
+func TestProcFSSockets(t *testing.T) {
+	list, err := newFakeSockets(t).fs().Sockets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range list {
+		got = append(got, s.String())
+	}
+	want := []string{
+		"tcp 0.0.0.0:8080 -> 0.0.0.0:0 LISTEN pid 100",
+		"tcp 127.0.0.1:8080 -> 127.0.0.1:50000 ESTABLISHED pid 100",
+		"tcp 127.0.0.1:50000 -> 127.0.0.1:8080 ESTABLISHED pid 200",
+		"tcp 127.0.0.1:50001 -> 127.0.0.1:8080 TIME_WAIT pid 0",
+		"tcp6 [::1]:80 -> [::]:0 LISTEN pid 300",
+		"udp 0.0.0.0:5353 -> 0.0.0.0:0 CLOSE pid 100",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("sockets = \n%v\nwant\n%v", got, want)
+	}
+	if list[1].UID != 1000 || list[1].Inode != 1002 {
+		t.Fatalf("socket = %+v", list[1])
+	}
+}
+
+func TestProcFSListenersOn(t *testing.T) {
+	fs := newFakeSockets(t).fs()
+	for port, pid := range map[int]int{8080: 100, 80: 300, 5353: 100} {
+		list, err := fs.ListenersOn(port)
+		if err != nil || len(list) != 1 || list[0].PID != pid || !list[0].Listening() {
+			t.Fatalf("%d = %+v %v", port, list, err)
+		}
+	}
+	if list, _ := fs.ListenersOn(50000); len(list) != 0 {
+		t.Fatalf("50000 = %+v", list)
+	}
+}
+
+func TestProcFSSocketsOf(t *testing.T) {
+	fs := newFakeSockets(t).fs()
+	list, err := fs.SocketsOf(100)
+	if err != nil || len(list) != 3 {
+		t.Fatalf("sockets of 100 = %+v %v", list, err)
+	}
+	// 201继承的socket也属于201自身
+	if list, _ = fs.SocketsOf(201); len(list) != 1 || list[0].PID != 201 || list[0].LocalPort != 8080 {
+		t.Fatalf("sockets of 201 = %+v", list)
+	}
+	if list, _ = fs.SocketsOf(1); len(list) != 0 {
+		t.Fatalf("sockets of 1 = %+v", list)
+	}
+	if _, err = fs.SocketsOf(999); !errors.Is(err, ErrProcessNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestDecodeSocketAddr(t *testing.T) {
+	for _, c := range []struct {
+		addr  string
+		order binary.ByteOrder
+		want  string
+	}{
+		{"0100007F:1F90", binary.LittleEndian, "127.0.0.1:8080"},
+		{"7F000001:1F90", binary.BigEndian, "127.0.0.1:8080"},
+		{"00000000000000000000000001000000:0050", binary.LittleEndian, "[::1]:80"},
+		{"00000000000000000000000000000001:0050", binary.BigEndian, "[::1]:80"},
+	} {
+		ip, port, ok := decodeSocketAddr(c.addr, c.order)
+		if got := net.JoinHostPort(ip.String(), fmt.Sprint(port)); !ok || got != c.want {
+			t.Fatalf("%s = %s", c.addr, got)
+		}
+	}
+	if _, _, ok := decodeSocketAddr("0100007F", binary.LittleEndian); ok {
+		t.Fatal("missing port should fail")
+	}
+}
+
+func TestListenersOnSelf(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux only")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	list, err := ListenersOn(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].PID != os.Getpid() || !list[0].LocalIP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("listeners = %+v", list)
+	}
+	mine, err := SocketsOf(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range mine {
+		found = found || s.LocalPort == port
+	}
+	if !found {
+		t.Fatalf("sockets of self = %+v", mine)
+	}
+}
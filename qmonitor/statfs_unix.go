@@ -0,0 +1,15 @@
+//go:build !windows
+
+package qmonitor
+
+import "syscall"
+
+// statfs 获取挂载点的总字节数、空闲字节数(含保留给root的部分)和非特权用户可用的字节数
+func statfs(path string) (uint64, uint64, uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, 0, err
+	}
+	bsize := uint64(st.Bsize)
+	return uint64(st.Blocks) * bsize, uint64(st.Bfree) * bsize, uint64(st.Bavail) * bsize, nil
+}
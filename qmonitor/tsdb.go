@@ -0,0 +1,684 @@
+package qmonitor
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRawSize 默认每个指标保留的原始点数量
+	DefaultRawSize = 3600
+	// DefaultMinuteSize 默认每个指标保留的1分钟汇总数量，即1天
+	DefaultMinuteSize = 1440
+	// DefaultHourSize 默认每个指标保留的1小时汇总数量，即30天
+	DefaultHourSize = 720
+	// DefaultSaveInterval 默认的自动保存间隔
+	DefaultSaveInterval = time.Minute * 5
+)
+
+var (
+	// ErrMetricNotFound 指标不存在
+	ErrMetricNotFound = errors.New("metric not found")
+	// ErrOutOfOrder 写入的点早于该指标最新的点
+	ErrOutOfOrder = errors.New("point out of order")
+)
+
+// tsdbMagic 持久化文件头，最后一个字节为版本号
+var tsdbMagic = []byte("QTSDB\x01")
+
+// Aggregation 聚合方式
+type Aggregation int
+
+const (
+	AggAvg Aggregation = iota
+	AggMin
+	AggMax
+	AggLast
+)
+
+func (a Aggregation) String() string {
+	switch a {
+	case AggMin:
+		return "min"
+	case AggMax:
+		return "max"
+	case AggLast:
+		return "last"
+	default:
+		return "avg"
+	}
+}
+
+// Point 指标点
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Rollup 一段时间内的汇总
+type Rollup struct {
+	Time  time.Time // 起始时间
+	Count int
+	Min   float64
+	Max   float64
+	Sum   float64
+	Last  float64
+}
+
+// Value 按聚合方式取值
+//
+//	@param agg
+//	@return float64
+func (r Rollup) Value(agg Aggregation) float64 {
+	switch agg {
+	case AggMin:
+		return r.Min
+	case AggMax:
+		return r.Max
+	case AggLast:
+		return r.Last
+	default:
+		if r.Count == 0 {
+			return 0
+		}
+		return r.Sum / float64(r.Count)
+	}
+}
+
+// merge 合并另一个汇总，o须晚于r
+func (r *Rollup) merge(o Rollup) {
+	if r.Count == 0 {
+		t := r.Time
+		*r = o
+		if !t.IsZero() {
+			r.Time = t
+		}
+		return
+	}
+	r.Count += o.Count
+	r.Min = math.Min(r.Min, o.Min)
+	r.Max = math.Max(r.Max, o.Max)
+	r.Sum += o.Sum
+	r.Last = o.Last
+}
+
+func pointRollup(p Point) Rollup {
+	return Rollup{Time: p.Time, Count: 1, Min: p.Value, Max: p.Value, Sum: p.Value, Last: p.Value}
+}
+
+// ring 固定容量的环形缓冲，满后覆盖最旧的元素
+type ring[T any] struct {
+	buf  []T
+	head int // 最旧元素的位置
+	n    int
+}
+
+func newRing[T any](size int) ring[T] {
+	return ring[T]{buf: make([]T, size)}
+}
+
+func (r *ring[T]) push(v T) {
+	if len(r.buf) == 0 {
+		return
+	}
+	if r.n < len(r.buf) {
+		r.buf[(r.head+r.n)%len(r.buf)] = v
+		r.n++
+		return
+	}
+	r.buf[r.head] = v
+	r.head = (r.head + 1) % len(r.buf)
+}
+
+// items 按从旧到新的顺序返回所有元素
+func (r *ring[T]) items() []T {
+	list := make([]T, 0, r.n)
+	for i := 0; i < r.n; i++ {
+		list = append(list, r.buf[(r.head+i)%len(r.buf)])
+	}
+	return list
+}
+
+// series 单个指标的三级存储
+type series struct {
+	raw    ring[Point]
+	minute ring[Rollup]
+	hour   ring[Rollup]
+	// 尚未结束的当前分钟、小时，Count为0表示没有
+	curMinute Rollup
+	curHour   Rollup
+	last      time.Time
+}
+
+// add 写入一个点，跨分钟、小时时将已结束的汇总写入对应的缓冲
+func (s *series) add(p Point) {
+	s.raw.push(p)
+	s.last = p.Time
+	roll := pointRollup(p)
+	for _, level := range []struct {
+		cur   *Rollup
+		ring  *ring[Rollup]
+		width time.Duration
+	}{{&s.curMinute, &s.minute, time.Minute}, {&s.curHour, &s.hour, time.Hour}} {
+		start := p.Time.Truncate(level.width)
+		if level.cur.Count > 0 && !level.cur.Time.Equal(start) {
+			level.ring.push(*level.cur)
+			*level.cur = Rollup{}
+		}
+		level.cur.Time = start
+		level.cur.merge(roll)
+	}
+}
+
+// tierWidths tiers返回的各级数据中每个元素覆盖的时长
+var tierWidths = []time.Duration{0, time.Minute, time.Hour}
+
+// tiers 从细到粗返回各级数据，均包含尚未结束的当前汇总
+func (s *series) tiers() [][]Rollup {
+	raw := s.raw.items()
+	rawRollups := make([]Rollup, len(raw))
+	for i, p := range raw {
+		rawRollups[i] = pointRollup(p)
+	}
+	minute := s.minute.items()
+	if s.curMinute.Count > 0 {
+		minute = append(minute, s.curMinute)
+	}
+	hour := s.hour.items()
+	if s.curHour.Count > 0 {
+		hour = append(hour, s.curHour)
+	}
+	return [][]Rollup{rawRollups, minute, hour}
+}
+
+// MetricStore 嵌入式时序存储，每个指标保留原始点、1分钟和1小时汇总三级固定大小的环形缓冲
+//
+// 查询时优先使用精度高的数据，超出其保留范围的部分使用下一级汇总
+type MetricStore struct {
+	Path         string        // Run自动保存和加载的文件，为空时不持久化
+	SaveInterval time.Duration // Run的保存间隔，0使用DefaultSaveInterval
+	RawSize      int           // 0使用DefaultRawSize，修改后对新建的指标生效
+	MinuteSize   int           // 0使用DefaultMinuteSize
+	HourSize     int           // 0使用DefaultHourSize
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+// NewMetricStore 创建时序存储
+//
+//	@param path 持久化文件，为空时不持久化
+//	@return *MetricStore
+func NewMetricStore(path string) *MetricStore {
+	return &MetricStore{Path: path, series: map[string]*series{}}
+}
+
+// Add 写入一个点
+//
+//	@param name 指标名称
+//	@param t
+//	@param value
+//	@return error 早于该指标最新的点时返回ErrOutOfOrder
+func (s *MetricStore) Add(name string, t time.Time, value float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sr := s.seriesLocked(name)
+	if t.Before(sr.last) {
+		return fmt.Errorf("%s at %s: %w", name, t.Format(time.RFC3339), ErrOutOfOrder)
+	}
+	sr.add(Point{Time: t, Value: value})
+	return nil
+}
+
+// AddMetrics 按MetricValues的指标名称写入主机指标
+//
+//	@param m
+//	@return error
+func (s *MetricStore) AddMetrics(m HostMetrics) error {
+	values := MetricValues(m)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		if err := s.Add(name, m.Time, values[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Write 实现MetricsSink，可直接添加到Collector
+func (s *MetricStore) Write(ctx context.Context, m HostMetrics) error {
+	return s.AddMetrics(m)
+}
+
+// Names 所有指标名称，已排序
+//
+//	@return []string
+func (s *MetricStore) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]string, 0, len(s.series))
+	for name := range s.series {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Latest 指标最新的点
+//
+//	@param name
+//	@return Point
+//	@return bool
+func (s *MetricStore) Latest(name string) (Point, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sr, ok := s.series[name]
+	if !ok || sr.raw.n == 0 {
+		return Point{}, false
+	}
+	return sr.raw.buf[(sr.raw.head+sr.raw.n-1)%len(sr.raw.buf)], true
+}
+
+// Query 按时间范围查询
+//
+//	@param name 指标名称
+//	@param from 起始时间，包含
+//	@param to 结束时间，包含
+//	@param step 按该间隔再次聚合，0时返回存储中的点，原始点以外的点为每个汇总按agg取值
+//	@param agg 聚合方式
+//	@return []Point 按时间排序，汇总的时间为其起始时间
+//	@return error 指标不存在时返回ErrMetricNotFound
+func (s *MetricStore) Query(name string, from, to time.Time, step time.Duration, agg Aggregation) ([]Point, error) {
+	s.mu.RLock()
+	sr, ok := s.series[name]
+	var tiers [][]Rollup
+	if ok {
+		tiers = sr.tiers()
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrMetricNotFound)
+	}
+
+	// 从细到粗拼接，粗粒度汇总只用于细粒度数据保留范围之前的部分
+	var rollups []Rollup
+	var cutoff time.Time // 已拼接数据的起始时间
+	for i, tier := range tiers {
+		if len(tier) == 0 {
+			continue
+		}
+		width := tierWidths[i]
+		if !cutoff.IsZero() {
+			// cutoff落在某个汇总中间时使用该汇总，丢弃细粒度数据中与其重叠的部分，避免重复计数
+			start := cutoff.Truncate(width)
+			if start.Before(cutoff) && !start.Before(from) && !tier[0].Time.After(start) {
+				cutoff = start.Add(width)
+				n := 0
+				for n < len(rollups) && rollups[n].Time.Before(cutoff) {
+					n++
+				}
+				rollups = rollups[n:]
+			}
+		}
+		var part []Rollup
+		for _, r := range tier {
+			if !cutoff.IsZero() && r.Time.Add(width).After(cutoff) {
+				break
+			}
+			if !r.Time.Before(from) && !r.Time.After(to) {
+				part = append(part, r)
+			}
+		}
+		rollups = append(part, rollups...)
+		if cutoff.IsZero() || tier[0].Time.Before(cutoff) {
+			cutoff = tier[0].Time
+		}
+		if !cutoff.After(from) {
+			break
+		}
+	}
+
+	if step <= 0 {
+		points := make([]Point, len(rollups))
+		for i, r := range rollups {
+			points[i] = Point{Time: r.Time, Value: r.Value(agg)}
+		}
+		return points, nil
+	}
+	var points []Point
+	var cur Rollup
+	for _, r := range rollups {
+		start := from.Add(r.Time.Sub(from) / step * step)
+		if cur.Count > 0 && !cur.Time.Equal(start) {
+			points = append(points, Point{Time: cur.Time, Value: cur.Value(agg)})
+			cur = Rollup{}
+		}
+		cur.Time = start
+		cur.merge(r)
+	}
+	if cur.Count > 0 {
+		points = append(points, Point{Time: cur.Time, Value: cur.Value(agg)})
+	}
+	return points, nil
+}
+
+// Save 以压缩的二进制格式保存所有指标
+//
+//	@param w
+//	@return error
+func (s *MetricStore) Save(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	zw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(zw)
+	e := &tsdbEncoder{w: bw}
+	e.bytes(tsdbMagic)
+	names := make([]string, 0, len(s.series))
+	for name := range s.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	e.uvarint(uint64(len(names)))
+	for _, name := range names {
+		sr := s.series[name]
+		e.uvarint(uint64(len(name)))
+		e.bytes([]byte(name))
+		e.prev = 0
+		e.time(sr.last)
+		raw := sr.raw.items()
+		e.uvarint(uint64(len(raw)))
+		e.prev = 0
+		for _, p := range raw {
+			e.time(p.Time)
+			e.float(p.Value)
+		}
+		for _, list := range [][]Rollup{sr.minute.items(), {sr.curMinute}, sr.hour.items(), {sr.curHour}} {
+			e.rollups(list)
+		}
+	}
+	if e.err != nil {
+		return e.err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Load 加载Save保存的数据，替换当前所有指标，超出缓冲容量的旧数据被丢弃
+//
+//	@param r
+//	@return error
+func (s *MetricStore) Load(r io.Reader) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	d := &tsdbDecoder{r: bufio.NewReader(zr)}
+	if magic := d.bytes(len(tsdbMagic)); d.err == nil && string(magic) != string(tsdbMagic) {
+		return errors.New("invalid metric store file")
+	}
+	loaded := map[string]*series{}
+	count := d.uvarint()
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		name := string(d.bytes(int(d.uvarint())))
+		sr := s.newSeries()
+		d.prev = 0
+		sr.last = d.time()
+		n := d.uvarint()
+		d.prev = 0
+		for j := uint64(0); j < n && d.err == nil; j++ {
+			sr.raw.push(Point{Time: d.time(), Value: d.float()})
+		}
+		for _, r := range d.rollups() {
+			sr.minute.push(r)
+		}
+		if cur := d.rollups(); len(cur) == 1 {
+			sr.curMinute = cur[0]
+		}
+		for _, r := range d.rollups() {
+			sr.hour.push(r)
+		}
+		if cur := d.rollups(); len(cur) == 1 {
+			sr.curHour = cur[0]
+		}
+		loaded[name] = sr
+	}
+	if d.err != nil {
+		return fmt.Errorf("load metric store: %w", d.err)
+	}
+	s.mu.Lock()
+	s.series = loaded
+	s.mu.Unlock()
+	return nil
+}
+
+// SaveToFile 保存到文件，先写入临时文件再替换
+//
+//	@param filePath
+//	@return error
+func (s *MetricStore) SaveToFile(filePath string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return err
+	}
+	tmpPath := filePath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err = s.Save(f); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
+}
+
+// LoadFromFile 从文件加载
+//
+//	@param filePath
+//	@return error
+func (s *MetricStore) LoadFromFile(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Load(f)
+}
+
+// Run 从Path加载数据，按间隔保存，ctx取消时保存后返回；Path为空时只等待ctx取消
+//
+//	@param ctx
+func (s *MetricStore) Run(ctx context.Context) {
+	if s.Path == "" {
+		<-ctx.Done()
+		return
+	}
+	if err := s.LoadFromFile(s.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Println(fmt.Sprintf("[MetricStore] Load error, %s", err))
+	}
+	ticker := time.NewTicker(durationOr(s.SaveInterval, DefaultSaveInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.SaveToFile(s.Path); err != nil {
+				log.Println(fmt.Sprintf("[MetricStore] Save error, %s", err))
+			}
+			return
+		case <-ticker.C:
+			if err := s.SaveToFile(s.Path); err != nil {
+				log.Println(fmt.Sprintf("[MetricStore] Save error, %s", err))
+			}
+		}
+	}
+}
+
+func (s *MetricStore) seriesLocked(name string) *series {
+	sr, ok := s.series[name]
+	if !ok {
+		sr = s.newSeries()
+		s.series[name] = sr
+	}
+	return sr
+}
+
+func (s *MetricStore) newSeries() *series {
+	size := func(n, def int) int {
+		if n <= 0 {
+			return def
+		}
+		return n
+	}
+	return &series{
+		raw:    newRing[Point](size(s.RawSize, DefaultRawSize)),
+		minute: newRing[Rollup](size(s.MinuteSize, DefaultMinuteSize)),
+		hour:   newRing[Rollup](size(s.HourSize, DefaultHourSize)),
+	}
+}
+
+// tsdbEncoder 持久化编码，时间为与上一个时间的毫秒差值，数值为8字节浮点
+type tsdbEncoder struct {
+	w    *bufio.Writer
+	buf  [binary.MaxVarintLen64]byte
+	prev int64
+	err  error
+}
+
+func (e *tsdbEncoder) bytes(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+func (e *tsdbEncoder) uvarint(v uint64) {
+	e.bytes(e.buf[:binary.PutUvarint(e.buf[:], v)])
+}
+
+func (e *tsdbEncoder) varint(v int64) {
+	e.bytes(e.buf[:binary.PutVarint(e.buf[:], v)])
+}
+
+func (e *tsdbEncoder) time(t time.Time) {
+	ms := int64(0)
+	if !t.IsZero() {
+		ms = t.UnixMilli()
+	}
+	e.varint(ms - e.prev)
+	e.prev = ms
+}
+
+func (e *tsdbEncoder) float(v float64) {
+	binary.LittleEndian.PutUint64(e.buf[:8], math.Float64bits(v))
+	e.bytes(e.buf[:8])
+}
+
+// rollups 编码汇总列表，Count为0的不写入
+func (e *tsdbEncoder) rollups(list []Rollup) {
+	n := 0
+	for _, r := range list {
+		if r.Count > 0 {
+			n++
+		}
+	}
+	e.uvarint(uint64(n))
+	e.prev = 0
+	for _, r := range list {
+		if r.Count == 0 {
+			continue
+		}
+		e.time(r.Time)
+		e.uvarint(uint64(r.Count))
+		e.float(r.Min)
+		e.float(r.Max)
+		e.float(r.Sum)
+		e.float(r.Last)
+	}
+}
+
+type tsdbDecoder struct {
+	r    *bufio.Reader
+	prev int64
+	err  error
+}
+
+func (d *tsdbDecoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > 1<<16 {
+		d.err = fmt.Errorf("invalid length %d", n)
+		return nil
+	}
+	b := make([]byte, n)
+	_, d.err = io.ReadFull(d.r, b)
+	return b
+}
+
+func (d *tsdbDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var v uint64
+	v, d.err = binary.ReadUvarint(d.r)
+	return v
+}
+
+func (d *tsdbDecoder) time() time.Time {
+	if d.err != nil {
+		return time.Time{}
+	}
+	var delta int64
+	if delta, d.err = binary.ReadVarint(d.r); d.err != nil {
+		return time.Time{}
+	}
+	d.prev += delta
+	if d.prev == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(d.prev)
+}
+
+func (d *tsdbDecoder) float() float64 {
+	b := d.bytes(8)
+	if d.err != nil {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+func (d *tsdbDecoder) rollups() []Rollup {
+	n := d.uvarint()
+	d.prev = 0
+	var list []Rollup
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		r := Rollup{Time: d.time(), Count: int(d.uvarint())}
+		r.Min, r.Max, r.Sum, r.Last = d.float(), d.float(), d.float(), d.float()
+		list = append(list, r)
+	}
+	return list
+}
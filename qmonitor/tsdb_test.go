@@ -0,0 +1,225 @@
+package qmonitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var tsdbStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func formatPoints(points []Point) string {
+	var buf bytes.Buffer
+	for _, p := range points {
+		fmt.Fprintf(&buf, "%s=%g ", p.Time.UTC().Format("15:04:05"), p.Value)
+	}
+	return buf.String()
+}
+
+func TestMetricStoreDownsampling(t *testing.T) {
+	s := NewMetricStore("")
+	s.RawSize = 12
+	// 每10秒一个点，共30分钟，值为序号
+	for i := 0; i < 180; i++ {
+		if err := s.Add("cpu", tsdbStart.Add(time.Duration(i)*time.Second*10), float64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	end := tsdbStart.Add(time.Minute * 30)
+
+	// 原始数据只保留最近2分钟
+	points, err := s.Query("cpu", end.Add(-time.Minute), end, 0, AggAvg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := formatPoints(points); got != "00:29:00=174 00:29:10=175 00:29:20=176 00:29:30=177 00:29:40=178 00:29:50=179 " {
+		t.Fatalf("raw = %s", got)
+	}
+
+	// 更早的部分使用1分钟汇总
+	points, _ = s.Query("cpu", tsdbStart, end, 0, AggMax)
+	if len(points) != 28+12 || points[0].Value != 5 || points[27].Value != 167 || points[28].Value != 168 {
+		t.Fatalf("stitched = %s", formatPoints(points))
+	}
+	points, _ = s.Query("cpu", tsdbStart, tsdbStart.Add(time.Minute*2), 0, AggAvg)
+	if got := formatPoints(points); got != "00:00:00=2.5 00:01:00=8.5 00:02:00=14.5 " {
+		t.Fatalf("minute avg = %s", got)
+	}
+
+	// 按10分钟再次聚合
+	for agg, want := range map[Aggregation]string{
+		AggMin:  "00:00:00=0 00:10:00=60 00:20:00=120 ",
+		AggMax:  "00:00:00=59 00:10:00=119 00:20:00=179 ",
+		AggLast: "00:00:00=59 00:10:00=119 00:20:00=179 ",
+		AggAvg:  "00:00:00=29.5 00:10:00=89.5 00:20:00=149.5 ",
+	} {
+		points, _ = s.Query("cpu", tsdbStart, end, time.Minute*10, agg)
+		if got := formatPoints(points); got != want {
+			t.Fatalf("%s = %s", agg, got)
+		}
+	}
+
+	if p, ok := s.Latest("cpu"); !ok || p.Value != 179 {
+		t.Fatalf("latest = %+v", p)
+	}
+}
+
+func TestMetricStoreHourly(t *testing.T) {
+	s := NewMetricStore("")
+	s.RawSize = 10
+	s.MinuteSize = 60
+	// 每分钟一个点，共5小时
+	for i := 0; i < 300; i++ {
+		_ = s.Add("mem", tsdbStart.Add(time.Duration(i)*time.Minute), float64(i%60))
+	}
+	end := tsdbStart.Add(time.Hour * 5)
+	points, err := s.Query("mem", tsdbStart, end, 0, AggAvg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 分钟汇总保留03:59之后，之前使用小时汇总，03:00的小时汇总替代03:59的分钟汇总，最后10分钟为原始点
+	if len(points) != 4+50+10 || points[0].Value != 29.5 || points[3].Time != tsdbStart.Add(time.Hour*3) {
+		t.Fatalf("points = %d %s", len(points), formatPoints(points[:5]))
+	}
+	points, _ = s.Query("mem", tsdbStart, end, time.Hour, AggMax)
+	if got := formatPoints(points); got != "00:00:00=59 01:00:00=59 02:00:00=59 03:00:00=59 04:00:00=59 " {
+		t.Fatalf("hourly max = %s", got)
+	}
+}
+
+func TestMetricStoreTierBoundaries(t *testing.T) {
+	s := NewMetricStore("")
+	// 原始点从02:51:40开始，分钟汇总从01:49开始，均不在上一级汇总的边界上
+	s.RawSize = 50
+	s.MinuteSize = 70
+	const n = 3 * 360
+	for i := 0; i < n; i++ {
+		_ = s.Add("cpu", tsdbStart.Add(time.Duration(i)*time.Second*10), float64(i))
+	}
+	end := tsdbStart.Add(time.Hour * 3)
+	points, _ := s.Query("cpu", tsdbStart, end, time.Hour*3, AggAvg)
+	if got := formatPoints(points); got != "00:00:00=539.5 " {
+		t.Fatalf("total avg = %s", got)
+	}
+	points, _ = s.Query("cpu", tsdbStart, end, time.Hour, AggAvg)
+	if got := formatPoints(points); got != "00:00:00=179.5 01:00:00=539.5 02:00:00=899.5 " {
+		t.Fatalf("hourly avg = %s", got)
+	}
+	points, _ = s.Query("cpu", tsdbStart.Add(time.Hour*2+time.Minute*50), end, 0, AggMin)
+	if got := formatPoints(points[:3]); got != "02:50:00=1020 02:51:00=1026 02:52:00=1032 " {
+		t.Fatalf("minute to raw = %s", got)
+	}
+	// 起始时间落在被替代的汇总中间时不使用该汇总
+	points, _ = s.Query("cpu", tsdbStart.Add(time.Hour*2+time.Minute*51+time.Second*30), end, time.Hour, AggAvg)
+	if len(points) != 1 || points[0].Value != 1054.5 {
+		t.Fatalf("partial = %s", formatPoints(points))
+	}
+}
+
+func TestMetricStoreErrors(t *testing.T) {
+	s := NewMetricStore("")
+	if err := s.Add("cpu", tsdbStart.Add(time.Minute), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("cpu", tsdbStart, 1); !errors.Is(err, ErrOutOfOrder) {
+		t.Fatalf("err = %v", err)
+	}
+	if err := s.Add("cpu", tsdbStart.Add(time.Minute), 2); err != nil {
+		t.Fatal("same time should be accepted")
+	}
+	if _, err := s.Query("disk", tsdbStart, tsdbStart, 0, AggAvg); !errors.Is(err, ErrMetricNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+	if err := s.Load(bytes.NewReader([]byte("not gzip"))); err == nil {
+		t.Fatal("invalid data should fail")
+	}
+}
+
+func TestMetricStorePersistence(t *testing.T) {
+	s := NewMetricStore("")
+	s.RawSize = 100
+	for i := 0; i < 3000; i++ {
+		ts := tsdbStart.Add(time.Duration(i) * time.Second * 5)
+		_ = s.Add("cpu", ts, float64(i%100)/3)
+		_ = s.Add("disk:/data", ts, 75)
+	}
+	var buf bytes.Buffer
+	if err := s.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// 2个指标，每个100个原始点、250个分钟汇总和5个小时汇总
+	if buf.Len() > 2*(100*9+255*40) {
+		t.Fatalf("size = %d", buf.Len())
+	}
+
+	loaded := NewMetricStore("")
+	loaded.RawSize = s.RawSize
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(loaded.Names()) != "[cpu disk:/data]" {
+		t.Fatalf("names = %v", loaded.Names())
+	}
+	end := tsdbStart.Add(time.Hour * 5)
+	for _, step := range []time.Duration{0, time.Minute * 7} {
+		want, _ := s.Query("cpu", tsdbStart, end, step, AggAvg)
+		got, _ := loaded.Query("cpu", tsdbStart, end, step, AggAvg)
+		if formatPoints(got) != formatPoints(want) {
+			t.Fatalf("step %v:\n%s\nwant\n%s", step, formatPoints(got), formatPoints(want))
+		}
+	}
+	// 加载后继续写入，当前分钟、小时的汇总被保留
+	next := tsdbStart.Add(time.Second * 5 * 3000)
+	if err := loaded.Add("cpu", next.Add(-time.Second*10), 0); !errors.Is(err, ErrOutOfOrder) {
+		t.Fatalf("err = %v", err)
+	}
+	_ = s.Add("cpu", next, 1)
+	_ = loaded.Add("cpu", next, 1)
+	want, _ := s.Query("cpu", tsdbStart, next, time.Hour, AggAvg)
+	got, _ := loaded.Query("cpu", tsdbStart, next, time.Hour, AggAvg)
+	if formatPoints(got) != formatPoints(want) {
+		t.Fatalf("after add:\n%s\nwant\n%s", formatPoints(got), formatPoints(want))
+	}
+}
+
+func TestMetricStoreRun(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data", "metrics.db")
+	s := NewMetricStore(file)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	m := HostMetrics{Time: tsdbStart, MemPercent: 42, Disks: []DiskMetrics{{Path: "/", UsedPercent: 10}}}
+	if err := s.Write(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	<-done
+	if _, err := os.Stat(file + ".tmp"); !os.IsNotExist(err) {
+		t.Fatal("temp file should be renamed")
+	}
+
+	s = NewMetricStore(file)
+	ctx, cancel = context.WithCancel(context.Background())
+	done = make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	waitUntil(t, time.Second*5, func() bool {
+		_, ok := s.Latest("disk:/")
+		return ok
+	})
+	cancel()
+	<-done
+	if p, ok := s.Latest("mem"); !ok || p.Value != 42 || !p.Time.Equal(tsdbStart) {
+		t.Fatalf("mem = %+v %v", p, ok)
+	}
+}
@@ -0,0 +1,400 @@
+package qmonitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultCheckInterval 默认的资源检查间隔
+	DefaultCheckInterval = time.Second * 5
+	// DefaultLimitCount 默认连续超限多少次后重启
+	DefaultLimitCount = 3
+)
+
+// ProcessSpec 受看护的进程
+type ProcessSpec struct {
+	Name string            // 名称，唯一，用于日志文件名和状态
+	Path string            // 可执行文件路径
+	Args []string          // 启动参数
+	Env  map[string]string // 追加的环境变量
+	Dir  string            // 工作目录，为空时使用当前目录
+
+	MaxRSS     uint64  // 常驻内存上限字节数，0不限制，仅Linux
+	MaxCPU     float64 // CPU占用率上限，100表示占满一个核，0不限制，仅Linux
+	LimitCount int     // 连续超限多少次检查后重启，0使用DefaultLimitCount
+
+	MinBackoff  time.Duration // 首次重启间隔，0为1秒
+	MaxBackoff  time.Duration // 最大重启间隔，0为1分钟；单次运行超过该时长后间隔重新计算
+	StopTimeout time.Duration // 停止时等待进程退出的时间，超时强制结束，0为10秒
+
+	LogFile     string // 标准输出和错误输出的日志文件，为空时使用 Watchdog.LogDir/<Name>.log，两者都为空时丢弃
+	LogMaxBytes int64  // 单个日志文件的最大字节数，0为10MB
+	LogMaxFiles int    // 保留的历史日志文件数量，0为5
+}
+
+// WatchStatus 受看护进程的状态
+type WatchStatus struct {
+	Name       string
+	PID        int // 当前进程号，未运行时为0
+	Running    bool
+	Restarts   int       // 已重启次数
+	StartedAt  time.Time // 最近一次启动时间
+	LastReason string    // 最近一次退出或重启的原因
+	RSS        uint64    // 最近一次检查时的常驻内存字节数
+	CPUPercent float64   // 最近一次检查间隔内的CPU占用率
+}
+
+// Watchdog 进程看护，启动一组进程，退出或资源超限时按指数退避间隔重启
+type Watchdog struct {
+	CheckInterval time.Duration // 资源检查间隔，0使用DefaultCheckInterval
+	LogDir        string        // 日志目录
+
+	mu      sync.Mutex
+	specs   []ProcessSpec
+	watches map[string]*watch
+	life    lifecycle
+}
+
+type watch struct {
+	spec   ProcessSpec
+	mu     sync.Mutex
+	status WatchStatus
+}
+
+// NewWatchdog 创建进程看护
+//
+//	@param specs 受看护的进程
+//	@return *Watchdog
+func NewWatchdog(specs ...ProcessSpec) *Watchdog {
+	return &Watchdog{specs: specs, watches: map[string]*watch{}, life: lifecycle{name: "watchdog"}}
+}
+
+// Add 添加受看护的进程，已启动时立即启动该进程
+//
+//	@param spec
+//	@return error 名称为空、重复或已调用Stop时返回错误
+func (w *Watchdog) Add(spec ProcessSpec) error {
+	if spec.Name == "" || spec.Path == "" {
+		return fmt.Errorf("process name and path are required")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.life.check(); err != nil {
+		return err
+	}
+	for _, s := range w.specs {
+		if s.Name == spec.Name {
+			return fmt.Errorf("process %s already exists", spec.Name)
+		}
+	}
+	w.specs = append(w.specs, spec)
+	if w.life.running() {
+		w.startLocked(spec)
+	}
+	return nil
+}
+
+// Start 启动所有进程，ctx取消或调用Stop时停止
+//
+//	@param ctx
+//	@return error 已启动或已调用Stop时返回错误
+func (w *Watchdog) Start(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.life.check(); err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for _, spec := range w.specs {
+		if spec.Name == "" || spec.Path == "" {
+			return fmt.Errorf("process name and path are required")
+		}
+		if names[spec.Name] {
+			return fmt.Errorf("process %s already exists", spec.Name)
+		}
+		names[spec.Name] = true
+	}
+	if err := w.life.start(ctx); err != nil {
+		return err
+	}
+	for _, spec := range w.specs {
+		w.startLocked(spec)
+	}
+	return nil
+}
+
+// Stop 停止所有进程，阻塞直到全部退出，之后不能再次启动或添加进程
+func (w *Watchdog) Stop() {
+	w.mu.Lock()
+	w.life.stop()
+	w.mu.Unlock()
+	w.life.wait()
+}
+
+// Status 获取所有进程的状态，按名称排序
+//
+//	@return []WatchStatus
+func (w *Watchdog) Status() []WatchStatus {
+	w.mu.Lock()
+	list := make([]WatchStatus, 0, len(w.watches))
+	for _, wt := range w.watches {
+		wt.mu.Lock()
+		list = append(list, wt.status)
+		wt.mu.Unlock()
+	}
+	w.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func (w *Watchdog) startLocked(spec ProcessSpec) {
+	wt := &watch{spec: spec, status: WatchStatus{Name: spec.Name}}
+	w.watches[spec.Name] = wt
+	w.life.goWith(func(ctx context.Context) {
+		w.run(ctx, wt)
+	})
+}
+
+// run 启动进程并在退出或超限时重启，直到ctx取消
+func (w *Watchdog) run(ctx context.Context, wt *watch) {
+	spec := wt.spec
+	minBackoff, maxBackoff := durationOr(spec.MinBackoff, time.Second), durationOr(spec.MaxBackoff, time.Minute)
+	out := w.logWriter(spec)
+	if out != nil {
+		defer out.Close()
+	}
+
+	backoff := minBackoff
+	for {
+		started := time.Now()
+		reason := w.runOnce(ctx, wt, out)
+		wt.mu.Lock()
+		wt.status.Running = false
+		wt.status.PID = 0
+		wt.status.LastReason = reason
+		wt.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) >= maxBackoff {
+			backoff = minBackoff
+		}
+		log.Println(fmt.Sprintf("[%s] %s, restart in %v", spec.Name, reason, backoff))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		wt.mu.Lock()
+		wt.status.Restarts++
+		wt.mu.Unlock()
+	}
+}
+
+// runOnce 运行一次进程，返回退出原因
+func (w *Watchdog) runOnce(ctx context.Context, wt *watch, out *rotateWriter) string {
+	spec := wt.spec
+	cmd := exec.Command(spec.Path, spec.Args...)
+	cmd.Dir = spec.Dir
+	if len(spec.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range spec.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+	if out != nil {
+		cmd.Stdout, cmd.Stderr = out, out
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Sprintf("start failed: %s", err)
+	}
+	wt.mu.Lock()
+	wt.status.PID = cmd.Process.Pid
+	wt.status.Running = true
+	wt.status.StartedAt = time.Now()
+	wt.status.RSS, wt.status.CPUPercent = 0, 0
+	wt.mu.Unlock()
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	interval := durationOr(w.CheckInterval, DefaultCheckInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	limitCount := spec.LimitCount
+	if limitCount <= 0 {
+		limitCount = DefaultLimitCount
+	}
+	var lastCPU time.Duration
+	lastCheck := time.Now()
+	breaches := 0
+	for {
+		select {
+		case err := <-exited:
+			if err != nil {
+				return fmt.Sprintf("exited: %s", err)
+			}
+			return "exited: exit status 0"
+		case <-ctx.Done():
+			terminate(cmd.Process, exited, durationOr(spec.StopTimeout, time.Second*10))
+			return "stopped"
+		case now := <-ticker.C:
+			p, err := findByPid(cmd.Process.Pid)
+			if err != nil {
+				continue
+			}
+			// 按检查间隔内的CPU时间计算占用率
+			cpu := float64(p.CPUTime-lastCPU) / float64(now.Sub(lastCheck)) * 100
+			lastCPU, lastCheck = p.CPUTime, now
+			wt.mu.Lock()
+			wt.status.RSS, wt.status.CPUPercent = p.RSS, cpu
+			wt.mu.Unlock()
+
+			reason := ""
+			if spec.MaxRSS > 0 && p.RSS > spec.MaxRSS {
+				reason = fmt.Sprintf("rss %d over limit %d", p.RSS, spec.MaxRSS)
+			} else if spec.MaxCPU > 0 && cpu > spec.MaxCPU {
+				reason = fmt.Sprintf("cpu %.1f%% over limit %.1f%%", cpu, spec.MaxCPU)
+			}
+			if reason == "" {
+				breaches = 0
+				continue
+			}
+			if breaches++; breaches >= limitCount {
+				terminate(cmd.Process, exited, durationOr(spec.StopTimeout, time.Second*10))
+				return reason
+			}
+		}
+	}
+}
+
+// logWriter 创建进程输出的日志文件
+func (w *Watchdog) logWriter(spec ProcessSpec) *rotateWriter {
+	file := spec.LogFile
+	if file == "" {
+		if w.LogDir == "" {
+			return nil
+		}
+		file = filepath.Join(w.LogDir, spec.Name+".log")
+	}
+	maxBytes := spec.LogMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 10 << 20
+	}
+	maxFiles := spec.LogMaxFiles
+	if maxFiles <= 0 {
+		maxFiles = 5
+	}
+	return &rotateWriter{path: file, maxBytes: maxBytes, maxFiles: maxFiles}
+}
+
+// terminate 先通知进程退出，超时后强制结束；Windows不支持SIGTERM，直接结束
+func terminate(p *os.Process, exited chan error, timeout time.Duration) {
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		_ = p.Kill()
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-exited:
+	case <-timer.C:
+		_ = p.Kill()
+		<-exited
+	}
+}
+
+func durationOr(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// rotateWriter 按大小轮转的日志文件，历史文件为 path.1 ~ path.N，数字越大越旧
+type rotateWriter struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func (r *rotateWriter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotateWriter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *rotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.file, r.size = f, st.Size()
+	return nil
+}
+
+func (r *rotateWriter) rotate() error {
+	_ = r.file.Close()
+	r.file = nil
+	_ = os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.open()
+}
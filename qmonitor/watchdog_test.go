@@ -0,0 +1,185 @@
+package qmonitor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWatchdogHelper 由Watchdog启动的子进程
+func TestWatchdogHelper(t *testing.T) {
+	mode := os.Getenv("WATCHDOG_HELPER")
+	if mode == "" {
+		t.Skip("helper process")
+	}
+	fmt.Println("helper started", os.Getenv("WATCHDOG_TAG"))
+	switch mode {
+	case "exit":
+		os.Exit(3)
+	case "busy":
+		for {
+		}
+	default:
+		time.Sleep(time.Minute)
+	}
+	os.Exit(0)
+}
+
+func helperSpec(t *testing.T, name, mode string) ProcessSpec {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ProcessSpec{
+		Name:        name,
+		Path:        exe,
+		Args:        []string{"-test.run=^TestWatchdogHelper$"},
+		Env:         map[string]string{"WATCHDOG_HELPER": mode, "WATCHDOG_TAG": name},
+		MinBackoff:  time.Millisecond * 10,
+		MaxBackoff:  time.Millisecond * 40,
+		StopTimeout: time.Second,
+	}
+}
+
+func statusOf(w *Watchdog, name string) WatchStatus {
+	for _, st := range w.Status() {
+		if st.Name == name {
+			return st
+		}
+	}
+	return WatchStatus{}
+}
+
+func waitUntil(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestWatchdogRestartOnExit(t *testing.T) {
+	dir := t.TempDir()
+	w := NewWatchdog(helperSpec(t, "crasher", "exit"), helperSpec(t, "sleeper", "sleep"))
+	w.LogDir = dir
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, time.Second*10, func() bool { return statusOf(w, "crasher").Restarts >= 2 })
+	waitUntil(t, time.Second*5, func() bool { return statusOf(w, "sleeper").Running })
+
+	st := statusOf(w, "crasher")
+	if !strings.Contains(st.LastReason, "exit status 3") {
+		t.Fatalf("status = %+v", st)
+	}
+	sleeper := statusOf(w, "sleeper")
+	if sleeper.Restarts != 0 || sleeper.PID == 0 {
+		t.Fatalf("sleeper = %+v", sleeper)
+	}
+
+	w.Stop()
+	for _, st = range w.Status() {
+		if st.Running || st.PID != 0 {
+			t.Fatalf("status after stop = %+v", st)
+		}
+	}
+	if statusOf(w, "sleeper").LastReason != "stopped" {
+		t.Fatalf("sleeper = %+v", statusOf(w, "sleeper"))
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "crasher.log"))
+	if err != nil || strings.Count(string(data), "helper started crasher") < 2 {
+		t.Fatalf("log = %q %v", data, err)
+	}
+}
+
+func TestWatchdogResourceLimit(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux only")
+	}
+	rss := helperSpec(t, "rss", "sleep")
+	rss.MaxRSS = 1
+	rss.LimitCount = 2
+	cpu := helperSpec(t, "cpu", "busy")
+	cpu.MaxCPU = 1
+
+	w := NewWatchdog(rss, cpu)
+	w.CheckInterval = time.Millisecond * 50
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	waitUntil(t, time.Second*10, func() bool {
+		return statusOf(w, "rss").Restarts >= 1 && statusOf(w, "cpu").Restarts >= 1
+	})
+	if st := statusOf(w, "rss"); !strings.HasPrefix(st.LastReason, "rss ") {
+		t.Fatalf("rss = %+v", st)
+	}
+	if st := statusOf(w, "cpu"); !strings.HasPrefix(st.LastReason, "cpu ") {
+		t.Fatalf("cpu = %+v", st)
+	}
+}
+
+func TestWatchdogInvalid(t *testing.T) {
+	w := NewWatchdog(ProcessSpec{Name: "a", Path: "/bin/true"}, ProcessSpec{Name: "a", Path: "/bin/true"})
+	if err := w.Start(context.Background()); err == nil {
+		t.Fatal("duplicate name should fail")
+	}
+	w = NewWatchdog()
+	if err := w.Add(ProcessSpec{Name: "a"}); err == nil {
+		t.Fatal("empty path should fail")
+	}
+
+	// 启动失败时按间隔重试
+	missing := ProcessSpec{Name: "missing", Path: filepath.Join(t.TempDir(), "missing"), MinBackoff: time.Millisecond}
+	if err := w.Add(missing); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, time.Second*5, func() bool { return statusOf(w, "missing").Restarts >= 2 })
+	w.Stop()
+	if st := statusOf(w, "missing"); !strings.HasPrefix(st.LastReason, "start failed") {
+		t.Fatalf("status = %+v", st)
+	}
+
+	// 停止后不能再添加或启动
+	if err := w.Add(ProcessSpec{Name: "late", Path: "/bin/true"}); err == nil {
+		t.Fatal("add after stop should fail")
+	}
+	if err := w.Start(context.Background()); err == nil {
+		t.Fatal("start after stop should fail")
+	}
+	if len(w.Status()) != 1 {
+		t.Fatalf("status = %+v", w.Status())
+	}
+}
+
+func TestRotateWriter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "logs", "app.log")
+	r := &rotateWriter{path: file, maxBytes: 10, maxFiles: 2}
+	for i := 0; i < 4; i++ {
+		if _, err := r.Write([]byte(fmt.Sprintf("line %d\n", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = r.Close()
+
+	for name, want := range map[string]string{"": "line 3\n", ".1": "line 2\n", ".2": "line 1\n"} {
+		data, err := os.ReadFile(file + name)
+		if err != nil || string(data) != want {
+			t.Fatalf("%s = %q %v", name, data, err)
+		}
+	}
+	if _, err := os.Stat(file + ".3"); !os.IsNotExist(err) {
+		t.Fatal("only 2 old files should be kept")
+	}
+}